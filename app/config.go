@@ -36,36 +36,80 @@ type Parameter pair
 
 // Config defines the multiple parameters which can be passed to NewHTTPPing
 type Config struct {
-	IPProtocol         string
-	Interval           time.Duration
-	Count              int64
-	Target             string
-	Method             string
-	UserAgent          string
-	Wait               time.Duration
-	DisableKeepAlive   bool
-	LogLevel           int8
-	ConnTarget         string
-	NoCheckCertificate bool
-	Cookies            []Cookie
-	Headers            []Header
-	Parameters         []Parameter
-	IgnoreServerErrors bool
-	ExtraParam         bool
-	DisableCompression bool
-	AudibleBell        bool
-	Referrer           string
-	AuthUsername       string
-	AuthPassword       string
-	DisableHTTP2       bool
-	FullDNS            bool
-	DNSServer          string
-	CacheDNSRequests   bool
-	KeepCookies        bool
-	FollowRedirects    bool
+	IPProtocol          string
+	Interval            time.Duration
+	Jitter              time.Duration
+	PoissonIntervals    bool
+	FixedRate           bool
+	MaxInFlight         int
+	CorrectOmission     bool
+	LoadRate            float64
+	LoadClients         int
+	LoadWindow          time.Duration
+	Count               int64
+	Duration            time.Duration
+	Deadline            time.Time
+	UntilSuccess        bool
+	UntilFailure        bool
+	Target              string
+	Method              string
+	UserAgent           string
+	Wait                time.Duration
+	DisableKeepAlive    bool
+	LogLevel            int8
+	ConnTarget          string
+	NoCheckCertificate  bool
+	Cookies             []Cookie
+	Headers             []Header
+	Parameters          []Parameter
+	IgnoreServerErrors  bool
+	ExtraParam          bool
+	DisableCompression  bool
+	AudibleBell         bool
+	Referrer            string
+	AuthUsername        string
+	AuthPassword        string
+	DisableHTTP2        bool
+	HTTP2PriorKnowledge bool
+	H2CUpgrade          bool
+	HTTP10              bool
+	FullDNS             bool
+	DNSServer           string
+	DNSClientSubnet     string
+	SourceIP            string
+	Interface           string
+	StartTLS            string
+	ALPN                []string
+	Proxy               string
+	ProxyUser           string
+	NoProxy             string
+	SOCKS5              string
+	SOCKS5RemoteDNS     bool
+	UnixSocket          string
+	GRPCService         string
+	WebSocketMessage    string
+	Data                string
+	UploadSize          int64
+	UploadFile          string
+	MaxBytes            int64
+	Throughput          bool
+	Progress            bool
+	Stream              bool
+	StreamFirstBytes    int64
+	StreamFirstLines    int64
+	StreamDuration      time.Duration
+	Histogram           bool
+	HistogramPhases     bool
+	SummaryInterval     time.Duration
+	Thresholds          []Threshold
+	JSONL               string
+	CacheDNSRequests    bool
+	KeepCookies         bool
+	FollowRedirects     bool
 }
 
 // RuntimeConfig defines the parameters which can be passed to NewPinger and NewWebClient
 type RuntimeConfig struct {
 	RedirectCallBack func(url string)
+	ProgressCallBack func(bytes int64, elapsed time.Duration)
 }
@@ -0,0 +1,56 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// newDialer builds the dialer used to open connections for the given network ("tcp", "udp", ...), the dialer is
+// bound to the source address and/or to the network interface defined in the config
+func newDialer(config *Config, network string) (*net.Dialer, error) {
+	dialer := &net.Dialer{}
+
+	if config.SourceIP != "" {
+		ip := net.ParseIP(config.SourceIP)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid source IP address: %s", config.SourceIP)
+		}
+
+		if strings.HasPrefix(network, "udp") {
+			dialer.LocalAddr = &net.UDPAddr{IP: ip}
+		} else {
+			dialer.LocalAddr = &net.TCPAddr{IP: ip}
+		}
+	}
+
+	if config.Interface != "" {
+		control, err := bindToDevice(config.Interface)
+		if err != nil {
+			return nil, err
+		}
+		dialer.Control = control
+	}
+
+	return dialer, nil
+}
+
+func isDialerBound(config *Config) bool {
+	return config.SourceIP != "" || config.Interface != ""
+}
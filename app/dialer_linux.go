@@ -0,0 +1,35 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"syscall"
+)
+
+// bindToDevice returns a dialer control function which binds sockets to a specific network interface
+// (SO_BINDTODEVICE), usually it requires the CAP_NET_RAW capability
+func bindToDevice(iface string) (func(network, address string, c syscall.RawConn) error, error) {
+	return func(_, _ string, c syscall.RawConn) error {
+		var bindErr error
+		if err := c.Control(func(fd uintptr) {
+			bindErr = syscall.BindToDevice(int(fd), iface)
+		}); err != nil {
+			return err
+		}
+		return bindErr
+	}, nil
+}
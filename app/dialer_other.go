@@ -0,0 +1,29 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux
+// +build !linux
+
+package app
+
+import (
+	"errors"
+	"syscall"
+)
+
+func bindToDevice(_ string) (func(network, address string, c syscall.RawConn) error, error) {
+	return nil, errors.New("binding to a network interface is only supported on Linux")
+}
@@ -0,0 +1,259 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const grpcHealthCheckPath = "/grpc.health.v1.Health/Check"
+
+// grpcStatusCodes are the names of the gRPC status codes, indexed by their values
+var grpcStatusCodes = []string{"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED", "NOT_FOUND",
+	"ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED", "FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE",
+	"UNIMPLEMENTED", "INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED"}
+
+// grpcServingStatuses are the names of the serving statuses of grpc.health.v1.HealthCheckResponse
+var grpcServingStatuses = []string{"UNKNOWN", "SERVING", "NOT_SERVING", "SERVICE_UNKNOWN"}
+
+const grpcServing = 1
+
+// grpcClientImpl calls the standard health check service of gRPC servers, the calls are done and measured by a web
+// client as gRPC relies on HTTP/2
+type grpcClientImpl struct {
+	*webClientImpl
+	target string
+}
+
+// NewGRPCClient builds a new instance of grpcClientImpl, the target is a grpc://host:port URL (cleartext HTTP/2) or a
+// grpcs://host:port URL (HTTP/2 over TLS)
+func NewGRPCClient(config *Config, runtimeConfig *RuntimeConfig) (WebClient, error) {
+	parsedURL, err := url.Parse(config.Target)
+	if err != nil {
+		return nil, err
+	}
+	if parsedURL.Port() == "" {
+		return nil, fmt.Errorf("port is missing in target %s", config.Target)
+	}
+
+	httpConfig := *config
+
+	scheme := "https"
+	if targetScheme(config.Target) == "grpc" {
+		scheme = "http"
+		httpConfig.HTTP2PriorKnowledge = true
+	}
+	httpConfig.Target = fmt.Sprintf("%s://%s%s", scheme, parsedURL.Host, grpcHealthCheckPath)
+	httpConfig.Method = http.MethodPost
+	httpConfig.Headers = append(append([]Header{}, config.Headers...),
+		Header{Name: "Content-Type", Value: "application/grpc"},
+		Header{Name: "TE", Value: "trailers"})
+
+	webClient, err := NewWebClient(&httpConfig, runtimeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	grpcClient := &grpcClientImpl{webClientImpl: webClient.(*webClientImpl), target: config.Target}
+
+	request := encodeGRPCMessage(encodeHealthCheckRequest(config.GRPCService))
+	grpcClient.requestBody = func() (io.Reader, int64, error) {
+		return bytes.NewReader(request), int64(len(request)), nil
+	}
+	grpcClient.checkResponse = checkGRPCResponse
+
+	return grpcClient, nil
+}
+
+func (grpcClient *grpcClientImpl) URL() string {
+	return grpcClient.target
+}
+
+// DoMeasure evaluates the latency of the health check of a gRPC server
+func (grpcClient *grpcClientImpl) DoMeasure(_ bool) *HTTPMeasure {
+	return grpcClient.webClientImpl.DoMeasureContext(context.Background(), false)
+}
+
+// DoMeasureContext does the same as DoMeasure, the call is aborted if ctx is canceled
+func (grpcClient *grpcClientImpl) DoMeasureContext(ctx context.Context, _ bool) *HTTPMeasure {
+	return grpcClient.webClientImpl.DoMeasureContext(ctx, false)
+}
+
+// checkGRPCResponse fills the gRPC statuses of the measure, a call fails unless it succeeds and the service is serving
+func checkGRPCResponse(res *http.Response, body []byte, measure *HTTPMeasure) {
+	fail := func(cause string) {
+		measure.IsFailure = true
+		measure.FailureCause = cause
+	}
+
+	if res.StatusCode != http.StatusOK {
+		fail(fmt.Sprintf("Unexpected HTTP status %d", res.StatusCode))
+		return
+	}
+
+	// the status is part of the headers if the response has no content (Trailers-Only response)
+	code := res.Trailer.Get("Grpc-Status")
+	message := res.Trailer.Get("Grpc-Message")
+	if code == "" {
+		code = res.Header.Get("Grpc-Status")
+		message = res.Header.Get("Grpc-Message")
+	}
+
+	statusCode, err := strconv.Atoi(code)
+	if err != nil {
+		fail("gRPC status is missing")
+		return
+	}
+
+	measure.GRPCStatus = statusName(grpcStatusCodes, statusCode)
+	if statusCode != 0 {
+		if unescaped, err := url.PathUnescape(message); err == nil {
+			message = unescaped
+		}
+		fail(fmt.Sprintf("gRPC status %s: %s", measure.GRPCStatus, message))
+		return
+	}
+
+	servingStatus, err := decodeHealthCheckResponse(body)
+	if err != nil {
+		fail(fmt.Sprintf("Malformed health check response: %s", err))
+		return
+	}
+
+	measure.ServingStatus = statusName(grpcServingStatuses, int(servingStatus))
+	if servingStatus != grpcServing {
+		fail(fmt.Sprintf("Service not serving (%s)", measure.ServingStatus))
+	}
+}
+
+func statusName(names []string, code int) string {
+	if code >= 0 && code < len(names) {
+		return names[code]
+	}
+	return strconv.Itoa(code)
+}
+
+// encodeHealthCheckRequest encodes a grpc.health.v1.HealthCheckRequest, the service is its only field
+func encodeHealthCheckRequest(service string) []byte {
+	if service == "" {
+		return nil
+	}
+	msg := []byte{1<<3 | protoWireBytes}
+	msg = append(msg, protoVarint(uint64(len(service)))...)
+	return append(msg, service...)
+}
+
+// decodeHealthCheckResponse decodes the serving status of a grpc.health.v1.HealthCheckResponse
+func decodeHealthCheckResponse(body []byte) (uint64, error) {
+	msg, err := decodeGRPCMessage(body)
+	if err != nil {
+		return 0, err
+	}
+
+	var status uint64
+	err = parseProtoFields(msg, func(field int, wireType int, value uint64, _ []byte) {
+		if field == 1 && wireType == protoWireVarint {
+			status = value
+		}
+	})
+	return status, err
+}
+
+// encodeGRPCMessage prefixes a message with its length, as messages are sent over HTTP/2 by gRPC
+func encodeGRPCMessage(msg []byte) []byte {
+	framed := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(framed[1:], uint32(len(msg)))
+	return append(framed, msg...)
+}
+
+func decodeGRPCMessage(body []byte) ([]byte, error) {
+	if len(body) < 5 {
+		return nil, errors.New("message is truncated")
+	}
+	if body[0] != 0 {
+		return nil, errors.New("compressed messages are not supported")
+	}
+	length := binary.BigEndian.Uint32(body[1:5])
+	if uint64(len(body)-5) < uint64(length) {
+		return nil, errors.New("message is truncated")
+	}
+	return body[5 : 5+length], nil
+}
+
+const (
+	protoWireVarint  = 0
+	protoWireFixed64 = 1
+	protoWireBytes   = 2
+	protoWireFixed32 = 5
+)
+
+func protoVarint(value uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutUvarint(buf, value)]
+}
+
+// parseProtoFields calls handle for each field of a protobuf message, value is set for varint fields and data for
+// length-delimited ones
+func parseProtoFields(msg []byte, handle func(field int, wireType int, value uint64, data []byte)) error {
+	errMalformed := errors.New("malformed protobuf message")
+
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return errMalformed
+		}
+		msg = msg[n:]
+
+		field, wireType := int(key>>3), int(key&7)
+		switch wireType {
+		case protoWireVarint:
+			value, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return errMalformed
+			}
+			msg = msg[n:]
+			handle(field, wireType, value, nil)
+		case protoWireBytes:
+			length, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < length {
+				return errMalformed
+			}
+			handle(field, wireType, 0, msg[n:n+int(length)])
+			msg = msg[n+int(length):]
+		case protoWireFixed64, protoWireFixed32:
+			size := 8
+			if wireType == protoWireFixed32 {
+				size = 4
+			}
+			if len(msg) < size {
+				return errMalformed
+			}
+			msg = msg[size:]
+		default:
+			return errMalformed
+		}
+	}
+	return nil
+}
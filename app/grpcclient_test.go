@@ -0,0 +1,118 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// healthHandler is a stand-in for the health service of a gRPC server
+func healthHandler(t *testing.T, statuses map[string]uint64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.URL.Path != grpcHealthCheckPath || r.Header.Get("Content-Type") != "application/grpc" {
+			http.Error(w, "not a gRPC health check", http.StatusBadRequest)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		msg, err := decodeGRPCMessage(body)
+		if err != nil {
+			t.Errorf("malformed request: %s", err)
+		}
+
+		var service string
+		_ = parseProtoFields(msg, func(field int, _ int, _ uint64, data []byte) {
+			if field == 1 {
+				service = string(data)
+			}
+		})
+
+		w.Header().Set("Content-Type", "application/grpc")
+		status, ok := statuses[service]
+		if !ok {
+			// Trailers-Only response
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "unknown service")
+			return
+		}
+
+		w.Header().Set("Trailer", "Grpc-Status")
+		_, _ = w.Write(encodeGRPCMessage(append([]byte{1 << 3}, protoVarint(status)...)))
+		w.Header().Set("Grpc-Status", "0")
+	})
+}
+
+func TestGRPCClient(t *testing.T) {
+	statuses := map[string]uint64{"": grpcServing, "backend": grpcServing, "down": 2}
+
+	cleartext := httptest.NewServer(h2c.NewHandler(healthHandler(t, statuses), &http2.Server{}))
+	defer cleartext.Close()
+
+	tlsServer := httptest.NewUnstartedServer(healthHandler(t, statuses))
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	targets := []string{
+		strings.Replace(cleartext.URL, "http://", "grpc://", 1),
+		strings.Replace(tlsServer.URL, "https://", "grpcs://", 1),
+	}
+
+	for _, target := range targets {
+		for _, service := range []string{"", "backend"} {
+			grpcClient, err := NewGRPCClient(&Config{Target: target, GRPCService: service, NoCheckCertificate: true}, &RuntimeConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if grpcClient.URL() != target {
+				t.Errorf("URL of gRPC client should be its target, got %s", grpcClient.URL())
+			}
+
+			for i := 0; i < 2; i++ {
+				measure := grpcClient.DoMeasure(false)
+				if measure.IsFailure || measure.GRPCStatus != "OK" || measure.ServingStatus != "SERVING" {
+					t.Fatalf("Health check of %s (%s) should have succeed: %s", target, service, measure.FailureCause)
+				}
+
+				if measure.Proto != "HTTP/2.0" || !measure.Wait.IsValid() || measure.SocketReused != (i > 0) {
+					t.Errorf("Measure of health check isn't consistent")
+				}
+			}
+		}
+
+		grpcClient, _ := NewGRPCClient(&Config{Target: target, GRPCService: "down", NoCheckCertificate: true}, &RuntimeConfig{})
+		if measure := grpcClient.DoMeasure(false); !measure.IsFailure || measure.FailureCause != "Service not serving (NOT_SERVING)" {
+			t.Errorf("Health check of a service not serving should have failed: %s", measure.FailureCause)
+		}
+
+		grpcClient, _ = NewGRPCClient(&Config{Target: target, GRPCService: "unknown", NoCheckCertificate: true}, &RuntimeConfig{})
+		if measure := grpcClient.DoMeasure(false); !measure.IsFailure || measure.FailureCause != "gRPC status NOT_FOUND: unknown service" {
+			t.Errorf("Health check of an unknown service should have failed: %s", measure.FailureCause)
+		}
+	}
+
+	if _, err := NewGRPCClient(&Config{Target: "grpc://localhost"}, &RuntimeConfig{}); err == nil {
+		t.Errorf("gRPC target without port should be refused")
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+)
+
+// h2cPriorKnowledgeTransport speaks HTTP/2 directly on cleartext connections (the server is assumed to support it),
+// other requests are handled by the fallback transport
+type h2cPriorKnowledgeTransport struct {
+	dial             func(ctx context.Context, network, addr string) (net.Conn, error)
+	fallback         http.RoundTripper
+	transport        *http2.Transport
+	disableKeepAlive bool
+
+	mutex      sync.Mutex
+	conn       net.Conn
+	clientConn *http2.ClientConn
+}
+
+func newH2CPriorKnowledgeTransport(config *Config, dial func(ctx context.Context, network, addr string) (net.Conn, error), fallback http.RoundTripper) *h2cPriorKnowledgeTransport {
+	return &h2cPriorKnowledgeTransport{
+		dial:             dial,
+		fallback:         fallback,
+		transport:        &http2.Transport{AllowHTTP: true, DisableCompression: config.DisableCompression},
+		disableKeepAlive: config.DisableKeepAlive,
+	}
+}
+
+func (transport *h2cPriorKnowledgeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" {
+		return transport.fallback.RoundTrip(req)
+	}
+
+	if transport.disableKeepAlive {
+		req = req.Clone(req.Context())
+		req.Close = true
+	}
+
+	conn, clientConn, reused, err := transport.getConn(req)
+	if err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+
+	if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{Conn: conn, Reused: reused})
+	}
+
+	return clientConn.RoundTrip(req)
+}
+
+// getConn returns the current HTTP/2 connection if it can take a new request, otherwise a new one is established
+func (transport *h2cPriorKnowledgeTransport) getConn(req *http.Request) (net.Conn, *http2.ClientConn, bool, error) {
+	ctx := req.Context()
+	trace := httptrace.ContextClientTrace(ctx)
+
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		addr = net.JoinHostPort(req.URL.Hostname(), portMap[req.URL.Scheme])
+	}
+
+	if trace != nil && trace.GetConn != nil {
+		trace.GetConn(addr)
+	}
+
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	if transport.clientConn != nil && transport.clientConn.CanTakeNewRequest() {
+		return transport.conn, transport.clientConn, true, nil
+	}
+
+	conn, err := transport.dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	clientConn, err := transport.transport.NewClientConn(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, false, err
+	}
+
+	transport.conn, transport.clientConn = conn, clientConn
+	return conn, clientConn, false, nil
+}
+
+// h2cUpgradeTransport upgrades cleartext HTTP/1.1 connections to HTTP/2 (RFC 7540 Section 3.2), as the upgraded
+// request is answered on the first stream of the new HTTP/2 connection, connections are not reused
+type h2cUpgradeTransport struct {
+	rawTransport
+	fallback http.RoundTripper
+}
+
+// h2cSettings are the settings sent by the client, push promises are not handled
+var h2cSettings = []http2.Setting{{ID: http2.SettingEnablePush, Val: 0}}
+
+func h2cSettingsHeader() string {
+	payload := make([]byte, 6*len(h2cSettings))
+	for i, setting := range h2cSettings {
+		binary.BigEndian.PutUint16(payload[6*i:], uint16(setting.ID))
+		binary.BigEndian.PutUint32(payload[6*i+2:], setting.Val)
+	}
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func (transport *h2cUpgradeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" {
+		return transport.fallback.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Connection", "Upgrade, HTTP2-Settings")
+	req.Header.Set("Upgrade", "h2c")
+	req.Header.Set("HTTP2-Settings", h2cSettingsHeader())
+
+	conn, err := transport.connect(req)
+	if err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+
+	if err := writeRequest(conn, req, "HTTP/1.1"); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		// the server ignored the upgrade, the response is a plain HTTP/1.1 one
+		gotFirstResponseByte(req)
+		res.Body = &connClosingBody{ReadCloser: res.Body, conn: conn}
+		return res, nil
+	}
+
+	stream := &h2cUpgradedStream{conn: conn, framer: http2.NewFramer(conn, reader)}
+	stream.framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+
+	res, err = stream.readResponse(req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return res, nil
+}
+
+// h2cUpgradedStream reads the response of the upgraded request, on the stream 1 of the HTTP/2 connection
+type h2cUpgradedStream struct {
+	conn   net.Conn
+	framer *http2.Framer
+	data   []byte
+	ended  bool
+}
+
+const h2cUpgradedStreamID = 1
+
+func (stream *h2cUpgradedStream) readResponse(req *http.Request) (*http.Response, error) {
+	if _, err := stream.conn.Write([]byte(http2.ClientPreface)); err != nil {
+		return nil, err
+	}
+	if err := stream.framer.WriteSettings(h2cSettings...); err != nil {
+		return nil, err
+	}
+
+	for {
+		frame, err := stream.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		headers, ok := frame.(*http2.MetaHeadersFrame)
+		if !ok {
+			continue
+		}
+
+		status := headers.PseudoValue("status")
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			return nil, fmt.Errorf("malformed HTTP/2 response status: %s", status)
+		}
+		if code/100 == 1 {
+			continue
+		}
+
+		gotFirstResponseByte(req)
+
+		res := &http.Response{
+			Status:        status + " " + http.StatusText(code),
+			StatusCode:    code,
+			Proto:         "HTTP/2.0",
+			ProtoMajor:    2,
+			Header:        make(http.Header),
+			ContentLength: -1,
+			Request:       req,
+			Body:          stream,
+		}
+		for _, field := range headers.RegularFields() {
+			res.Header.Add(http.CanonicalHeaderKey(field.Name), field.Value)
+		}
+		if contentLength, err := strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64); err == nil {
+			res.ContentLength = contentLength
+		}
+
+		stream.ended = headers.StreamEnded()
+		return res, nil
+	}
+}
+
+// readFrame reads the next frame of the upgraded stream, frames related to the connection are handled on the fly
+func (stream *h2cUpgradedStream) readFrame() (http2.Frame, error) {
+	for {
+		frame, err := stream.framer.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if !f.IsAck() {
+				if err := stream.framer.WriteSettingsAck(); err != nil {
+					return nil, err
+				}
+			}
+		case *http2.PingFrame:
+			if !f.IsAck() {
+				if err := stream.framer.WritePing(true, f.Data); err != nil {
+					return nil, err
+				}
+			}
+		case *http2.RSTStreamFrame:
+			if f.StreamID == h2cUpgradedStreamID {
+				return nil, fmt.Errorf("stream reset by the server: %s", f.ErrCode)
+			}
+		case *http2.GoAwayFrame:
+			if f.LastStreamID < h2cUpgradedStreamID {
+				return nil, fmt.Errorf("connection closed by the server: %s", f.ErrCode)
+			}
+		default:
+			if frame.Header().StreamID == h2cUpgradedStreamID {
+				return frame, nil
+			}
+		}
+	}
+}
+
+func (stream *h2cUpgradedStream) Read(p []byte) (int, error) {
+	for len(stream.data) == 0 {
+		if stream.ended {
+			return 0, io.EOF
+		}
+
+		frame, err := stream.readFrame()
+		if err != nil {
+			return 0, err
+		}
+
+		stream.ended = frame.Header().Flags.Has(http2.FlagDataEndStream) || frame.Header().Flags.Has(http2.FlagHeadersEndStream)
+
+		data, ok := frame.(*http2.DataFrame)
+		if !ok {
+			// trailers are ignored
+			continue
+		}
+
+		// the frame buffer is reused by the framer, its content has to be copied
+		stream.data = append(stream.data[:0], data.Data()...)
+
+		if length := data.Header().Length; length > 0 {
+			if err := stream.framer.WriteWindowUpdate(0, length); err != nil {
+				return 0, err
+			}
+			if !stream.ended {
+				if err := stream.framer.WriteWindowUpdate(h2cUpgradedStreamID, length); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+
+	n := copy(p, stream.data)
+	stream.data = stream.data[n:]
+	return n, nil
+}
+
+func (stream *h2cUpgradedStream) Close() error {
+	return stream.conn.Close()
+}
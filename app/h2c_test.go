@@ -0,0 +1,148 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var largePayload = bytes.Repeat([]byte("http-ping"), 100000)
+
+func newH2CTestServer() *httptest.Server {
+	return httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+		if r.URL.Path == "/large" {
+			_, _ = w.Write(largePayload)
+		} else {
+			_, _ = w.Write([]byte("Hello"))
+		}
+	}), &http2.Server{}))
+}
+
+func TestHTTP2PriorKnowledge(t *testing.T) {
+	ts := newH2CTestServer()
+	defer ts.Close()
+
+	webClient, _ := NewWebClient(&Config{Target: ts.URL + "/large", HTTP2PriorKnowledge: true, Method: "GET"}, &RuntimeConfig{})
+
+	for i := 0; i < 2; i++ {
+		measure := webClient.DoMeasure(false)
+		if measure.IsFailure || measure.StatusCode != 200 || measure.Proto != "HTTP/2.0" || measure.Headers.Get("X-Proto") != "HTTP/2.0" {
+			t.Fatalf("Request with HTTP/2 prior knowledge should have succeed: %s %s", measure.Proto, measure.FailureCause)
+		}
+
+		if measure.Bytes != int64(len(largePayload)) || measure.SocketReused != (i > 0) || !measure.Wait.IsValid() {
+			t.Errorf("Measure with HTTP/2 prior knowledge isn't consistent")
+		}
+	}
+}
+
+func TestH2CUpgrade(t *testing.T) {
+	ts := newH2CTestServer()
+	defer ts.Close()
+
+	for _, path := range []string{"/", "/large"} {
+		webClient, _ := NewWebClient(&Config{Target: ts.URL + path, H2CUpgrade: true, Method: "GET"}, &RuntimeConfig{})
+
+		measure := webClient.DoMeasure(false)
+		if measure.IsFailure || measure.StatusCode != 200 || measure.Proto != "HTTP/2.0" || measure.Headers.Get("X-Proto") != "HTTP/2.0" {
+			t.Fatalf("Request with h2c upgrade should have succeed: %s %s", measure.Proto, measure.FailureCause)
+		}
+
+		if path == "/large" && measure.Bytes != int64(len(largePayload)) {
+			t.Errorf("Response body hasn't been entirely read: %d bytes", measure.Bytes)
+		}
+	}
+
+	// the server doesn't support h2c, the exchange stays in HTTP/1.1
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("Hello"))
+	}))
+	defer ts.Close()
+
+	webClient, _ := NewWebClient(&Config{Target: ts.URL, H2CUpgrade: true, Method: "GET"}, &RuntimeConfig{})
+
+	measure := webClient.DoMeasure(false)
+	if measure.IsFailure || measure.StatusCode != 200 || measure.Proto != "HTTP/1.1" || measure.Bytes != 5 {
+		t.Fatalf("Request with ignored h2c upgrade should have succeed: %s %s", measure.Proto, measure.FailureCause)
+	}
+}
+
+func TestHTTP10(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+		w.Header().Set("X-Connection", r.Header.Get("Connection"))
+		_, _ = w.Write([]byte("Hello"))
+	}))
+	defer ts.Close()
+
+	webClient, _ := NewWebClient(&Config{Target: ts.URL, HTTP10: true, NoCheckCertificate: true, Method: "GET"}, &RuntimeConfig{})
+
+	for i := 0; i < 2; i++ {
+		measure := webClient.DoMeasure(false)
+		if measure.IsFailure || measure.StatusCode != 200 || measure.Proto != "HTTP/1.0" || measure.Headers.Get("X-Proto") != "HTTP/1.0" {
+			t.Fatalf("HTTP/1.0 request should have succeed: %s %s", measure.Proto, measure.FailureCause)
+		}
+
+		if measure.Headers.Get("X-Connection") != "close" || measure.SocketReused || !measure.TLSEnabled || !measure.TLSDuration.IsValid() || measure.Bytes != 5 {
+			t.Errorf("HTTP/1.0 measure isn't consistent")
+		}
+	}
+}
+
+type closeTrackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (body *closeTrackingBody) Close() error {
+	body.closed = true
+	return nil
+}
+
+func TestRawTransportsCloseBodyOnConnectFailure(t *testing.T) {
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+
+	transports := map[string]http.RoundTripper{
+		"HTTP/1.0":               &http10Transport{rawTransport: rawTransport{dial: dial}},
+		"h2c upgrade":            &h2cUpgradeTransport{rawTransport: rawTransport{dial: dial}},
+		"HTTP/2 prior knowledge": newH2CPriorKnowledgeTransport(&Config{}, dial, nil),
+	}
+
+	for name, transport := range transports {
+		body := &closeTrackingBody{Reader: bytes.NewReader(largePayload)}
+		req, _ := http.NewRequest("POST", "http://localhost/", body)
+
+		if _, err := transport.RoundTrip(req); err == nil {
+			t.Fatalf("Request with %s should have failed", name)
+		}
+		if !body.closed {
+			t.Errorf("Request body should have been closed after the failed connection with %s", name)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fever.ch/http-ping/stats"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	histogramBuckets = 10
+	histogramWidth   = 50
+	heatmapRows      = 8
+	heatmapColumns   = 60
+)
+
+var heatShades = []rune(" ░▒▓█")
+
+// histogramPrecision returns the number of decimals needed to tell the bounds of the buckets apart
+func histogramPrecision(histogram *stats.Histogram) int {
+	if histogram.Max-histogram.Min < stats.Measure(10*time.Millisecond) {
+		return 3
+	}
+	return 1
+}
+
+// writeHistogram prints the distribution of the measures as horizontal bars
+func writeHistogram(stdout io.Writer, label string, measures []stats.Measure) {
+	histogram := stats.HistogramFromLatencies(measures, histogramBuckets)
+	if histogram == nil {
+		return
+	}
+
+	var highest int64
+	for _, count := range histogram.Counts {
+		if count > highest {
+			highest = count
+		}
+	}
+
+	precision := histogramPrecision(histogram)
+
+	_, _ = fmt.Fprintf(stdout, "\n%s histogram:\n", label)
+	for i, count := range histogram.Counts {
+		_, _ = fmt.Fprintf(stdout, "%8.*f - %8.*f ms │%-*s %d\n", precision, histogram.Lower(i).ToFloat(time.Millisecond),
+			precision, histogram.Lower(i+1).ToFloat(time.Millisecond), histogramWidth, strings.Repeat("█", int(math.Ceil(float64(count)/float64(highest)*histogramWidth))), count)
+	}
+}
+
+// writeHeatmap prints the distribution of the latencies over the run, each column is a time bucket and each row a
+// latency bucket, the darker a cell is the more measures it contains
+func writeHeatmap(stdout io.Writer, latencies []stats.Measure, offsets []time.Duration) {
+	histogram := stats.HistogramFromLatencies(latencies, heatmapRows)
+	if histogram == nil {
+		return
+	}
+
+	duration := offsets[len(offsets)-1]
+	columns := heatmapColumns
+	if len(latencies) < columns {
+		columns = len(latencies)
+	}
+
+	rows := len(histogram.Counts)
+	cells := make([][]int64, rows)
+	for i := range cells {
+		cells[i] = make([]int64, columns)
+	}
+	var highest int64
+	for i, latency := range latencies {
+		column := 0
+		if duration > 0 {
+			column = int(float64(offsets[i]) / float64(duration) * float64(columns))
+		}
+		if column >= columns {
+			column = columns - 1
+		}
+		row := histogram.Bucket(latency)
+		cells[row][column]++
+		if cells[row][column] > highest {
+			highest = cells[row][column]
+		}
+	}
+
+	_, _ = fmt.Fprintf(stdout, "\nlatency heatmap:\n")
+	for row := rows - 1; row >= 0; row-- {
+		line := make([]rune, columns)
+		for column, count := range cells[row] {
+			line[column] = heatShades[int(math.Ceil(float64(count)/float64(highest)*float64(len(heatShades)-1)))]
+		}
+		_, _ = fmt.Fprintf(stdout, "%8.*f ms │%s\n", histogramPrecision(histogram), histogram.Lower(row).ToFloat(time.Millisecond), string(line))
+	}
+	end := duration.Truncate(time.Second).String()
+	_, _ = fmt.Fprintf(stdout, "           └%s\n", strings.Repeat("─", columns))
+	_, _ = fmt.Fprintf(stdout, "            0s%*s\n", columns-2, end)
+}
+
+// writeDistributions prints the histogram and the heatmap of the latencies, and optionally the histograms of the phases
+func writeDistributions(stdout io.Writer, config *Config, latencies []stats.Measure, offsets []time.Duration, phases [][]stats.Measure) {
+	writeHistogram(stdout, "latency", latencies)
+
+	if config.HistogramPhases {
+		for i, phase := range tuiPhases {
+			var measures []stats.Measure
+			var total stats.Measure
+			for _, measurePhases := range phases {
+				measures = append(measures, measurePhases[i])
+				total += measurePhases[i]
+			}
+			if total > 0 {
+				writeHistogram(stdout, phase.label, measures)
+			}
+		}
+	}
+
+	writeHeatmap(stdout, latencies, offsets)
+}
@@ -0,0 +1,195 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+)
+
+// rawTransport performs HTTP/1 exchanges on dedicated connections, it's used when the transport of net/http cannot
+// do the job (HTTP/1.0 requests and h2c upgrades)
+type rawTransport struct {
+	dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+	tlsConfig *tls.Config
+}
+
+// connect establishes a new connection with the server of req, the hooks of the client trace are called like the
+// transport of net/http would do
+func (transport *rawTransport) connect(req *http.Request) (net.Conn, error) {
+	ctx := req.Context()
+	trace := httptrace.ContextClientTrace(ctx)
+
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		addr = net.JoinHostPort(req.URL.Hostname(), portMap[req.URL.Scheme])
+	}
+
+	if trace != nil && trace.GetConn != nil {
+		trace.GetConn(addr)
+	}
+
+	conn, err := transport.dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if req.URL.Scheme == "https" {
+		tlsConfig := transport.tlsConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = req.URL.Hostname()
+		}
+
+		if trace != nil && trace.TLSHandshakeStart != nil {
+			trace.TLSHandshakeStart()
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		err = tlsConn.HandshakeContext(ctx)
+		if trace != nil && trace.TLSHandshakeDone != nil {
+			trace.TLSHandshakeDone(tlsConn.ConnectionState(), err)
+		}
+
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	if trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{Conn: conn})
+	}
+	return conn, nil
+}
+
+// protoWriter replaces the protocol of the request line, net/http always writes HTTP/1.1 requests
+type protoWriter struct {
+	io.Writer
+	proto   string
+	started bool
+}
+
+// Write expects the first write to contain the whole request line, which is the case with the buffered writer of
+// net/http (the first line is either buffered or written at once)
+func (writer *protoWriter) Write(p []byte) (int, error) {
+	if writer.started {
+		return writer.Writer.Write(p)
+	}
+	writer.started = true
+
+	if _, err := writer.Writer.Write(bytes.Replace(p, []byte(" HTTP/1.1\r\n"), []byte(" "+writer.proto+"\r\n"), 1)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeRequest sends req on conn, using proto in the request line, the body is streamed to the connection
+func writeRequest(conn net.Conn, req *http.Request, proto string) error {
+	err := req.Write(&protoWriter{Writer: conn, proto: proto})
+
+	if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.WroteRequest != nil {
+		trace.WroteRequest(httptrace.WroteRequestInfo{Err: err})
+	}
+	return err
+}
+
+// waitResponse blocks until the first byte of the response is available
+func waitResponse(reader *bufio.Reader) error {
+	_, err := reader.Peek(1)
+	return err
+}
+
+func gotFirstResponseByte(req *http.Request) {
+	if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.GotFirstResponseByte != nil {
+		trace.GotFirstResponseByte()
+	}
+}
+
+// connClosingBody closes the underlying connection once the body has been consumed
+type connClosingBody struct {
+	io.ReadCloser
+	conn net.Conn
+}
+
+func (body *connClosingBody) Close() error {
+	err := body.ReadCloser.Close()
+	_ = body.conn.Close()
+	return err
+}
+
+// closeRequestBody closes the body of a request which couldn't be sent, like the transports of net/http do
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+func connectionState(conn net.Conn) *tls.ConnectionState {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		return &state
+	}
+	return nil
+}
+
+// http10Transport does HTTP/1.0 requests, a new connection is used for each of them
+type http10Transport struct {
+	rawTransport
+}
+
+func (transport *http10Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Close = true
+
+	conn, err := transport.connect(req)
+	if err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+
+	if err := writeRequest(conn, req, "HTTP/1.0"); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	if err := waitResponse(reader); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	gotFirstResponseByte(req)
+
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	res.TLS = connectionState(conn)
+	res.Body = &connClosingBody{ReadCloser: res.Body, conn: conn}
+	return res, nil
+}
@@ -17,11 +17,15 @@
 package app
 
 import (
+	"context"
 	"fever.ch/http-ping/stats"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -31,19 +35,63 @@ type HTTPPing interface {
 }
 
 type httpPingImpl struct {
-	config *Config
-	stdout io.Writer
-	pinger Pinger
-	logger logger
+	config    *Config
+	stdout    io.Writer
+	pinger    Pinger
+	observers *observers
+	progress  *progressLine
 }
 
-// NewHTTPPing builds a new instance of HTTPPing or error if something goes wrong
+// NewHTTPPing builds a new instance of HTTPPing printing its results on stdout (the output depends on LogLevel), or
+// error if something goes wrong
 func NewHTTPPing(config *Config, stdout io.Writer) (HTTPPing, error) {
+	var logger Observer
+
+	if config.LoadRate > 0 {
+		logger = newLoadLogger(config, stdout)
+	} else if config.LogLevel == 0 {
+		logger = newQuietLogger(config, stdout)
+	} else if config.LogLevel == 2 {
+		logger = newVerboseLogger(config, stdout)
+	} else if config.LogLevel == 3 {
+		logger = newTUILogger(config, stdout)
+	} else {
+		logger = newStandardLogger(config, stdout)
+	}
+
+	observers := []Observer{logger}
+
+	var jsonl *jsonlObserver
+	if config.JSONL != "" {
+		var err error
+		if jsonl, err = newJSONLFileObserver(config.JSONL); err != nil {
+			return nil, err
+		}
+		observers = append(observers, jsonl)
+	}
+
+	httpPing, err := NewHTTPPingWithObservers(config, stdout, observers...)
+	if err != nil && jsonl != nil {
+		// the file is otherwise closed at the end of the run
+		_ = jsonl.closer.Close()
+	}
+	return httpPing, err
+}
+
+// NewHTTPPingWithObservers builds a new instance of HTTPPing which notifies each of the observers, stdout is only used
+// for the interactive features (progress, bell, interim statistics and histograms), or error if something goes wrong
+func NewHTTPPingWithObservers(config *Config, stdout io.Writer, observerList ...Observer) (HTTPPing, error) {
+	observers := &observers{list: observerList}
 
 	runtimeConfig := &RuntimeConfig{
-		RedirectCallBack: func(url string) {
-			_, _ = fmt.Fprintf(stdout, "   ─→     Redirected to %s\n\n", url)
-		},
+		RedirectCallBack: observers.OnRedirect,
+	}
+
+	progress := &progressLine{stdout: stdout}
+	// the dashboard is redrawn after each measure, there's no room for a progress line
+	// (nor with the requests in flight simultaneously in load mode)
+	if config.Progress && config.LogLevel > 0 && config.LogLevel != 3 && config.LoadRate == 0 {
+		runtimeConfig.ProgressCallBack = progress.show
 	}
 
 	pinger, err := NewPinger(config, runtimeConfig)
@@ -52,21 +100,12 @@ func NewHTTPPing(config *Config, stdout io.Writer) (HTTPPing, error) {
 		return nil, err
 	}
 
-	var logger logger
-
-	if config.LogLevel == 0 {
-		logger = newQuietLogger(config, stdout, pinger)
-	} else if config.LogLevel == 2 {
-		logger = newVerboseLogger(config, stdout, pinger)
-	} else {
-		logger = newStandardLogger(config, stdout, pinger)
-	}
-
 	return &httpPingImpl{
-		config: config,
-		stdout: stdout,
-		pinger: pinger,
-		logger: logger,
+		config:    config,
+		stdout:    stdout,
+		pinger:    pinger,
+		observers: observers,
+		progress:  progress,
 	}, nil
 }
 
@@ -80,13 +119,52 @@ func (httpPingImpl *httpPingImpl) Run() error {
 
 	signal.Notify(ic, os.Interrupt)
 
-	ch := httpPingImpl.pinger.Ping()
+	sc := make(chan os.Signal, 1)
+
+	if len(summarySignals) > 0 {
+		signal.Notify(sc, summarySignals...)
+		defer signal.Stop(sc)
+	}
+
+	// a nil channel never fires, there's no periodic summary by default
+	var periodicSummary <-chan time.Time
+	if config.SummaryInterval > 0 {
+		ticker := time.NewTicker(config.SummaryInterval)
+		defer ticker.Stop()
+		periodicSummary = ticker.C
+	}
 
-	_, _ = fmt.Fprintf(stdout, "HTTP-PING %s %s\n\n", httpPingImpl.pinger.URL(), config.Method)
+	// the observers are notified before the pinger starts, it may notify redirections right away
+	url := httpPingImpl.pinger.URL()
+	if isHTTPTarget(url) {
+		httpPingImpl.observers.OnStart(url, config.Method)
+	} else {
+		httpPingImpl.observers.OnStart(url, "")
+	}
+
+	// the pinger (and the request in progress) is stopped when the run ends
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// without PingContext, the pinger runs until its end, even if the run is interrupted
+	var ch <-chan *HTTPMeasure
+	if contextPinger, ok := httpPingImpl.pinger.(ContextPinger); ok {
+		ch = contextPinger.PingContext(ctx)
+	} else {
+		ch = httpPingImpl.pinger.Ping()
+	}
 
 	successes := 0
 	attempts := 0
+	skipped := 0
 	var latencies []stats.Measure
+	// the measures of the requests sent and the slots of the schedule which have been skipped, for coordinated omission
+	var attempted []*HTTPMeasure
+	var skippedSlots []time.Time
+	var rates, uploadRates []float64
+	var offsets []time.Duration
+	var phases [][]stats.Measure
+	start := time.Now()
 
 	var loop = true
 	for loop {
@@ -94,128 +172,403 @@ func (httpPingImpl *httpPingImpl) Run() error {
 		case measure := <-ch:
 			if measure == nil {
 				loop = false
+			} else if measure.Skipped {
+				skipped++
+				skippedSlots = append(skippedSlots, measure.IntendedStart)
 			} else {
-				httpPingImpl.logger.onMeasure(measure, attempts)
+				httpPingImpl.progress.clear()
+				httpPingImpl.observers.OnMeasure(measure, attempts)
 				attempts++
+				attempted = append(attempted, measure)
 				if !measure.IsFailure {
 					successes++
 					latencies = append(latencies, measure.TotalTime)
+					offsets = append(offsets, time.Since(start))
+					phases = append(phases, measurePhases(measure))
+					rates = append(rates, measure.DownloadRate())
+					if measure.RequestBytes > 0 {
+						uploadRates = append(uploadRates, measure.UploadRate())
+					}
 					if config.AudibleBell {
 						_, _ = fmt.Fprintf(stdout, "\a")
 					}
 				}
 			}
+		case <-sc:
+			httpPingImpl.summary(attempts, successes, latencies)
+		case <-periodicSummary:
+			httpPingImpl.summary(attempts, successes, latencies)
 		case <-ic:
 			loop = false
 		}
 	}
-	var lossRate = float64(0)
-	if attempts > 0 {
-		lossRate = float64(100*(attempts-successes)) / float64(attempts)
+	summary := &Summary{
+		URL:       url,
+		Attempts:  int64(attempts),
+		Successes: int64(successes),
+		Skipped:   int64(skipped),
+		LossRate:  lossRate(attempts, successes),
 	}
+	if successes > 0 {
+		summary.PingStats = stats.PingStatsFromLatencies(latencies)
+		summary.Percentiles = stats.Percentiles(latencies, 50, 90, 95, 99)
+		if config.CorrectOmission {
+			summary.CorrectedPercentiles = stats.Percentiles(httpPingImpl.correctedLatencies(attempted, skippedSlots, time.Now()), 50, 90, 95, 99)
+		}
+	}
+	if config.Throughput {
+		summary.Download = stats.RateStatsFromRates(rates)
+		summary.Upload = stats.RateStatsFromRates(uploadRates)
+	}
+	summary.Exceeded = checkThresholds(config.Thresholds, summary.LossRate, latencies)
 
-	httpPingImpl.logger.onClose(int64(attempts), int64(successes), lossRate, stats.PingStatsFromLatencies(latencies))
+	httpPingImpl.observers.OnClose(summary)
+
+	if config.Histogram && successes > 0 {
+		writeDistributions(stdout, config, latencies, offsets, phases)
+	}
+
+	if successes == 0 {
+		return &ExitError{Code: 1, Reason: "no answer received"}
+	}
+	if len(summary.Exceeded) > 0 {
+		return &ExitError{Code: 1, Reason: fmt.Sprintf("threshold exceeded: %s", strings.Join(summary.Exceeded, ", "))}
+	}
 	return nil
 }
 
-type logger interface {
-	onMeasure(httpMeasure *HTTPMeasure, id int)
-	onClose(attempts int64, success int64, lossRate float64, pingStats *stats.PingStats)
+// correctedLatencies returns the latencies of the answered measures corrected for coordinated omission. With a fixed
+// rate, the latencies are taken from the slots at which the requests should have been sent, and each slot skipped as
+// too many requests were in flight is accounted as if its request had been queued until the next request actually
+// sent, answered or not (or until the end of the run). Otherwise, the requests which couldn't be sent while a request
+// was in flight are accounted with the expected interval.
+func (httpPingImpl *httpPingImpl) correctedLatencies(attempted []*HTTPMeasure, skippedSlots []time.Time, end time.Time) []stats.Measure {
+	config := httpPingImpl.config
+
+	latencies := make([]stats.Measure, 0, len(attempted)+len(skippedSlots))
+	for _, measure := range attempted {
+		if !measure.IsFailure {
+			latencies = append(latencies, measure.TotalTime+measure.ScheduleDelay())
+		}
+	}
+
+	if !config.FixedRate && config.LoadRate == 0 {
+		return stats.CorrectedLatencies(latencies, stats.Measure(scheduleInterval(config)))
+	}
+
+	// the measures are received in the order of their completion, not of their slot
+	sent := append([]*HTTPMeasure(nil), attempted...)
+	sort.Slice(sent, func(i, j int) bool { return sent[i].IntendedStart.Before(sent[j].IntendedStart) })
+
+	for _, slot := range skippedSlots {
+		next := sort.Search(len(sent), func(i int) bool { return sent[i].IntendedStart.After(slot) })
+		if next == len(sent) {
+			latencies = append(latencies, stats.Measure(end.Sub(slot)))
+		} else if sent[next].IsFailure {
+			// the duration of a failed request isn't known, only the time spent waiting for it is accounted
+			latencies = append(latencies, stats.Measure(sent[next].Start.Sub(slot)))
+		} else {
+			latencies = append(latencies, stats.Measure(sent[next].Start.Sub(slot))+sent[next].TotalTime)
+		}
+	}
+	return latencies
 }
 
-type quietLogger struct {
+// summary prints the interim statistics of the run without stopping it
+func (httpPingImpl *httpPingImpl) summary(attempts int, successes int, latencies []stats.Measure) {
+	httpPingImpl.progress.clear()
+
+	stdout := httpPingImpl.stdout
+	_, _ = fmt.Fprintf(stdout, "   ─→     %d requests sent, %d answers received, %.1f%% loss\n", attempts, successes, lossRate(attempts, successes))
+	if successes > 0 {
+		_, _ = fmt.Fprintf(stdout, "          %s\n", stats.PingStatsFromLatencies(latencies).String())
+		_, _ = fmt.Fprintf(stdout, "          percentiles p50/p90/p95/p99 = %s ms\n", formatPercentiles(stats.Percentiles(latencies, 50, 90, 95, 99)))
+	}
+	_, _ = fmt.Fprintf(stdout, "\n")
+}
+
+// lossRate returns the percentage of the attempts which didn't succeed
+func lossRate(attempts int, successes int) float64 {
+	if attempts == 0 {
+		return 0
+	}
+	return float64(100*(attempts-successes)) / float64(attempts)
+}
+
+// progressLine displays the progress of downloads on a line which is overwritten until the measure is complete
+type progressLine struct {
+	mutex  sync.Mutex
+	stdout io.Writer
+	length int
+}
+
+func (progress *progressLine) show(bytes int64, elapsed time.Duration) {
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+
+	line := fmt.Sprintf("          downloading: %.1f MB, %.3f MB/s", float64(bytes)/1e6, float64(bytes)/1e6/elapsed.Seconds())
+	_, _ = fmt.Fprintf(progress.stdout, "\r%-*s", progress.length, line)
+	progress.length = len(line)
+}
+
+func (progress *progressLine) clear() {
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+
+	if progress.length > 0 {
+		_, _ = fmt.Fprintf(progress.stdout, "\r%s\r", strings.Repeat(" ", progress.length))
+		progress.length = 0
+	}
+}
+
+// rates returns a short description of the transfer rates of a measure
+func (measure *HTTPMeasure) rates() string {
+	rates := fmt.Sprintf("rate=%.3f MB/s (wire=%.3f MB/s)", measure.DownloadRate()/1e6, measure.WireDownloadRate()/1e6)
+	if measure.RequestBytes > 0 {
+		rates += fmt.Sprintf(", upload=%.3f MB/s", measure.UploadRate()/1e6)
+	}
+	return rates
+}
+
+// details returns a short description of the outcome of a successful measure
+func (measure *HTTPMeasure) details() string {
+	switch measure.Proto {
+	case protoTCP:
+		return "connected"
+	case protoTLS:
+		return fmt.Sprintf("tls version=%s, cipher=%s", measure.TLSVersion, measure.TLSCipherSuite)
+	}
+	if measure.Proto == protoWebSocket {
+		return fmt.Sprintf("%s, size=%d bytes", measure.WebSocketMessage, measure.Bytes)
+	}
+	if measure.GRPCStatus != "" {
+		return fmt.Sprintf("grpc status=%s, serving status=%s", measure.GRPCStatus, measure.ServingStatus)
+	}
+	if measure.Truncated {
+		return fmt.Sprintf("code=%d, size=%d bytes (truncated)", measure.StatusCode, measure.Bytes)
+	}
+	if measure.Stream != nil {
+		return fmt.Sprintf("code=%d, size=%d bytes, events=%d, first event=%.1f ms", measure.StatusCode, measure.Bytes,
+			measure.Stream.Events, measure.Stream.TimeToFirstEvent.ToFloat(time.Millisecond))
+	}
+	return fmt.Sprintf("code=%d, size=%d bytes", measure.StatusCode, measure.Bytes)
+}
+
+// writeStream prints the timings of a stream
+func writeStream(stdout io.Writer, stream *StreamStats) {
+	_, _ = fmt.Fprintf(stdout, "          stream: first byte=%.1f ms, first event=%.1f ms, events=%d",
+		stream.TimeToFirstByte.ToFloat(time.Millisecond), stream.TimeToFirstEvent.ToFloat(time.Millisecond), stream.Events)
+	if stream.GapAverage.IsValid() {
+		_, _ = fmt.Fprintf(stdout, ", gap avg=%.1f ms, gap max=%.1f ms", stream.GapAverage.ToFloat(time.Millisecond), stream.GapMax.ToFloat(time.Millisecond))
+	}
+	if stream.Truncated {
+		_, _ = fmt.Fprintf(stdout, ", truncated")
+	}
+	_, _ = fmt.Fprintf(stdout, "\n")
+}
+
+// textLogger prints the start of the run and the redirections, it's common to the loggers printing text
+type textLogger struct {
 	config *Config
 	stdout io.Writer
-	pinger Pinger
 }
 
-func newQuietLogger(config *Config, stdout io.Writer, pinger Pinger) logger {
-	return &quietLogger{config: config, stdout: stdout, pinger: pinger}
+func (textLogger *textLogger) OnStart(url string, method string) {
+	if method != "" {
+		_, _ = fmt.Fprintf(textLogger.stdout, "HTTP-PING %s %s\n\n", url, method)
+	} else {
+		_, _ = fmt.Fprintf(textLogger.stdout, "HTTP-PING %s\n\n", url)
+	}
 }
 
-func (quietLogger *quietLogger) onMeasure(_ *HTTPMeasure, _ int) {
+func (textLogger *textLogger) OnRedirect(url string) {
+	_, _ = fmt.Fprintf(textLogger.stdout, "   ─→     Redirected to %s\n\n", url)
 }
 
-func (quietLogger *quietLogger) onClose(attempts int64, successes int64, lossRate float64, pingStats *stats.PingStats) {
+// writeSummary prints the statistics of the run
+func (textLogger *textLogger) writeSummary(summary *Summary) {
+	stdout := textLogger.stdout
 
-	_, _ = fmt.Fprintf(quietLogger.stdout, "--- %s ping statistics ---\n", quietLogger.pinger.URL())
+	_, _ = fmt.Fprintf(stdout, "--- %s ping statistics ---\n", summary.URL)
 
-	_, _ = fmt.Fprintf(quietLogger.stdout, "%d requests sent, %d answers received, %.1f%% loss\n", attempts, successes, lossRate)
+	_, _ = fmt.Fprintf(stdout, "%d requests sent, %d answers received, %.1f%% loss", summary.Attempts, summary.Successes, summary.LossRate)
+	if summary.Skipped > 0 {
+		_, _ = fmt.Fprintf(stdout, ", %d skipped (too many requests in flight)", summary.Skipped)
+	}
+	_, _ = fmt.Fprintf(stdout, "\n")
 
-	if successes > 0 {
-		_, _ = fmt.Fprintf(quietLogger.stdout, "%s\n", pingStats.String())
+	if summary.Successes > 0 {
+		_, _ = fmt.Fprintf(stdout, "%s\n", summary.PingStats.String())
+		if summary.CorrectedPercentiles != nil {
+			_, _ = fmt.Fprintf(stdout, "percentiles p50/p90/p95/p99 = %s ms\n", formatPercentiles(summary.Percentiles))
+			_, _ = fmt.Fprintf(stdout, "corrected percentiles p50/p90/p95/p99 = %s ms (coordinated omission)\n", formatPercentiles(summary.CorrectedPercentiles))
+		}
+		if summary.Download != nil {
+			_, _ = fmt.Fprintf(stdout, "%s\n", summary.Download.String())
+		}
+		if summary.Upload != nil {
+			_, _ = fmt.Fprintf(stdout, "upload %s\n", summary.Upload.String())
+		}
+	}
+}
+
+// formatPercentiles returns the percentiles in milliseconds separated with slashes
+func formatPercentiles(percentiles []stats.Measure) string {
+	var values []string
+	for _, p := range percentiles {
+		values = append(values, fmt.Sprintf("%.3f", p.ToFloat(time.Millisecond)))
+	}
+	return strings.Join(values, "/")
+}
+
+// writeExceeded prints the thresholds which have been exceeded
+func (textLogger *textLogger) writeExceeded(summary *Summary) {
+	for _, exceeded := range summary.Exceeded {
+		_, _ = fmt.Fprintf(textLogger.stdout, "threshold exceeded: %s\n", exceeded)
 	}
 }
 
+type quietLogger struct {
+	textLogger
+}
+
+func newQuietLogger(config *Config, stdout io.Writer) Observer {
+	return &quietLogger{textLogger{config: config, stdout: stdout}}
+}
+
+func (quietLogger *quietLogger) OnMeasure(_ *HTTPMeasure, _ int) {
+}
+
+func (quietLogger *quietLogger) OnClose(summary *Summary) {
+	quietLogger.writeSummary(summary)
+	quietLogger.writeExceeded(summary)
+}
+
 type standardLogger struct {
-	config *Config
-	stdout io.Writer
-	pinger Pinger
+	textLogger
 }
 
-func newStandardLogger(config *Config, stdout io.Writer, pinger Pinger) logger {
-	return &standardLogger{config: config, stdout: stdout, pinger: pinger}
+func newStandardLogger(config *Config, stdout io.Writer) Observer {
+	return &standardLogger{textLogger{config: config, stdout: stdout}}
 }
 
-func (standardLogger *standardLogger) onMeasure(measure *HTTPMeasure, id int) {
+func (standardLogger *standardLogger) OnMeasure(measure *HTTPMeasure, id int) {
 
 	if measure.IsFailure {
 		_, _ = fmt.Fprintf(standardLogger.stdout, "%4d: Error: %s\n", id, measure.FailureCause)
 		return
 	}
-	_, _ = fmt.Fprintf(standardLogger.stdout, "%8d: %s, code=%d, size=%d bytes, time=%.1f ms\n", id, measure.RemoteAddr, measure.StatusCode, measure.Bytes, measure.TotalTime.ToFloat(time.Millisecond))
+	details := measure.details()
+	if standardLogger.config.Throughput {
+		details += ", " + measure.rates()
+	}
+	_, _ = fmt.Fprintf(standardLogger.stdout, "%8d: %s, %s, time=%.1f ms\n", id, measure.RemoteAddr, details, measure.TotalTime.ToFloat(time.Millisecond))
 
 }
 
-func (standardLogger *standardLogger) onClose(attempts int64, successes int64, lossRate float64, pingStats *stats.PingStats) {
+func (standardLogger *standardLogger) OnClose(summary *Summary) {
 	_, _ = fmt.Fprintf(standardLogger.stdout, "\n")
-	_, _ = fmt.Fprintf(standardLogger.stdout, "--- %s ping statistics ---\n", standardLogger.pinger.URL())
-
-	_, _ = fmt.Fprintf(standardLogger.stdout, "%d requests sent, %d answers received, %.1f%% loss\n", attempts, successes, lossRate)
-
-	if successes > 0 {
-		_, _ = fmt.Fprintf(standardLogger.stdout, "%s\n", pingStats.String())
-	}
+	standardLogger.writeSummary(summary)
+	standardLogger.writeExceeded(summary)
 }
 
 type verboseLogger struct {
-	config     *Config
-	stdout     io.Writer
+	textLogger
 	measureSum *HTTPMeasure
-	pinger     Pinger
 }
 
-func newVerboseLogger(config *Config, stdout io.Writer, pinger Pinger) logger {
-	return &verboseLogger{config: config, stdout: stdout, pinger: pinger,
+func newVerboseLogger(config *Config, stdout io.Writer) Observer {
+	return &verboseLogger{textLogger: textLogger{config: config, stdout: stdout},
 		measureSum: &HTTPMeasure{
 			DNSResolution: stats.MeasureNotValid,
 			TCPHandshake:  stats.MeasureNotValid,
 			TLSDuration:   stats.MeasureNotValid,
+
+			StartTLSDuration: stats.MeasureNotValid,
+
+			ProxyConnection:  stats.MeasureNotValid,
+			ProxyTLSDuration: stats.MeasureNotValid,
+			ProxyTunnel:      stats.MeasureNotValid,
+			SOCKSNegotiation: stats.MeasureNotValid,
+
+			WebSocketUpgrade: stats.MeasureNotValid,
 		},
 	}
 }
 
-func (verboseLogger *verboseLogger) onMeasure(measure *HTTPMeasure, id int) {
+func (verboseLogger *verboseLogger) OnMeasure(measure *HTTPMeasure, id int) {
 
 	if measure.IsFailure {
 		_, _ = fmt.Fprintf(verboseLogger.stdout, "%4d: Error: %s\n", id, measure.FailureCause)
 		return
 	}
 
-	_, _ = fmt.Fprintf(verboseLogger.stdout, "%8d: %s, code=%d, size=%d bytes, time=%.1f ms\n", id, measure.RemoteAddr, measure.StatusCode, measure.Bytes, measure.TotalTime.ToFloat(time.Millisecond))
-	_, _ = fmt.Fprintf(verboseLogger.stdout, "          proto=%s, socket reused=%t, compressed=%t\n", measure.Proto, measure.SocketReused, measure.Compressed)
-	_, _ = fmt.Fprintf(verboseLogger.stdout, "          network i/o: bytes read=%d, bytes written=%d\n", measure.InBytes, measure.OutBytes)
+	_, _ = fmt.Fprintf(verboseLogger.stdout, "%8d: %s, %s, time=%.1f ms\n", id, measure.RemoteAddr, measure.details(), measure.TotalTime.ToFloat(time.Millisecond))
+	if measure.Proto != protoTCP && measure.Proto != protoTLS {
+		_, _ = fmt.Fprintf(verboseLogger.stdout, "          proto=%s, socket reused=%t, compressed=%t\n", measure.Proto, measure.SocketReused, measure.Compressed)
+		_, _ = fmt.Fprintf(verboseLogger.stdout, "          network i/o: bytes read=%d, bytes written=%d\n", measure.InBytes, measure.OutBytes)
+		if verboseLogger.config.Throughput {
+			_, _ = fmt.Fprintf(verboseLogger.stdout, "          throughput: %s\n", measure.rates())
+		}
+	}
 
 	if measure.TLSEnabled {
-		_, _ = fmt.Fprintf(verboseLogger.stdout, "          tls version=%s\n", measure.TLSVersion)
+		_, _ = fmt.Fprintf(verboseLogger.stdout, "          tls version=%s, cipher=%s", measure.TLSVersion, measure.TLSCipherSuite)
+		if measure.TLSALPN != "" {
+			_, _ = fmt.Fprintf(verboseLogger.stdout, ", alpn=%s", measure.TLSALPN)
+		}
+		_, _ = fmt.Fprintf(verboseLogger.stdout, "\n")
+	}
+
+	for i, cert := range measure.TLSCertificates {
+		_, _ = fmt.Fprintf(verboseLogger.stdout, "          certificate %d: subject=%s, issuer=%s, expires in %d days (%s)\n", i,
+			cert.Subject.CommonName, cert.Issuer.CommonName, int(time.Until(cert.NotAfter).Hours()/24), cert.NotAfter.Format("2006-01-02"))
+	}
+
+	if measure.TCPInfo != nil {
+		_, _ = fmt.Fprintf(verboseLogger.stdout, "          tcp info: rtt=%.1f ms, rttvar=%.1f ms, retransmits=%d, cwnd=%d, mss=%d",
+			float64(measure.TCPInfo.RTT)/float64(time.Millisecond), float64(measure.TCPInfo.RTTVar)/float64(time.Millisecond),
+			measure.TCPInfo.Retransmits, measure.TCPInfo.CongestionWindow, measure.TCPInfo.MSS)
+		if measure.TCPInfo.DeliveryRate > 0 {
+			_, _ = fmt.Fprintf(verboseLogger.stdout, ", delivery rate=%.1f kB/s", float64(measure.TCPInfo.DeliveryRate)/1000)
+		}
+		_, _ = fmt.Fprintf(verboseLogger.stdout, "\n")
 	}
 
+	if measure.Stream != nil {
+		writeStream(verboseLogger.stdout, measure.Stream)
+
+		if verboseLogger.measureSum.Stream == nil {
+			verboseLogger.measureSum.Stream = &StreamStats{TimeToFirstByte: stats.MeasureNotValid, TimeToFirstEvent: stats.MeasureNotValid,
+				GapAverage: stats.MeasureNotValid, GapMax: stats.MeasureNotValid}
+		}
+		sum := verboseLogger.measureSum.Stream
+		sum.TimeToFirstByte = sum.TimeToFirstByte.SumIfValid(measure.Stream.TimeToFirstByte)
+		sum.TimeToFirstEvent = sum.TimeToFirstEvent.SumIfValid(measure.Stream.TimeToFirstEvent)
+		sum.Events += measure.Stream.Events
+		sum.GapAverage = sum.GapAverage.SumIfValid(measure.Stream.GapAverage)
+		if !sum.GapMax.IsValid() || measure.Stream.GapMax > sum.GapMax {
+			sum.GapMax = measure.Stream.GapMax
+		}
+	}
+
+	verboseLogger.measureSum.Proto = measure.Proto
 	verboseLogger.measureSum.TotalTime += measure.TotalTime
 
 	verboseLogger.measureSum.ConnEstablishment = verboseLogger.measureSum.ConnEstablishment.SumIfValid(measure.ConnEstablishment)
 	verboseLogger.measureSum.DNSResolution = verboseLogger.measureSum.DNSResolution.SumIfValid(measure.DNSResolution)
 	verboseLogger.measureSum.TCPHandshake = verboseLogger.measureSum.TCPHandshake.SumIfValid(measure.TCPHandshake)
 	verboseLogger.measureSum.TLSDuration = verboseLogger.measureSum.TLSDuration.SumIfValid(measure.TLSDuration)
+	verboseLogger.measureSum.StartTLS = measure.StartTLS
+	verboseLogger.measureSum.StartTLSDuration = verboseLogger.measureSum.StartTLSDuration.SumIfValid(measure.StartTLSDuration)
+	verboseLogger.measureSum.Proxy = measure.Proxy
+	verboseLogger.measureSum.UnixSocket = measure.UnixSocket
+	verboseLogger.measureSum.ProxyConnection = verboseLogger.measureSum.ProxyConnection.SumIfValid(measure.ProxyConnection)
+	verboseLogger.measureSum.ProxyTLSDuration = verboseLogger.measureSum.ProxyTLSDuration.SumIfValid(measure.ProxyTLSDuration)
+	verboseLogger.measureSum.ProxyTunnel = verboseLogger.measureSum.ProxyTunnel.SumIfValid(measure.ProxyTunnel)
+	verboseLogger.measureSum.SOCKSNegotiation = verboseLogger.measureSum.SOCKSNegotiation.SumIfValid(measure.SOCKSNegotiation)
+	verboseLogger.measureSum.WebSocketMessage = measure.WebSocketMessage
+	verboseLogger.measureSum.WebSocketUpgrade = verboseLogger.measureSum.WebSocketUpgrade.SumIfValid(measure.WebSocketUpgrade)
 	verboseLogger.measureSum.RequestSending += measure.RequestSending
 	verboseLogger.measureSum.Wait += measure.Wait
 	verboseLogger.measureSum.ResponseIngesting += measure.ResponseIngesting
@@ -229,53 +582,100 @@ func (verboseLogger *verboseLogger) onMeasure(measure *HTTPMeasure, id int) {
 	_, _ = fmt.Fprintf(verboseLogger.stdout, "\n")
 }
 
-func (verboseLogger *verboseLogger) onClose(attempts int64, successes int64, lossRate float64, pingStats *stats.PingStats) {
+func (verboseLogger *verboseLogger) OnClose(summary *Summary) {
 	_, _ = fmt.Fprintf(verboseLogger.stdout, "\n")
-	_, _ = fmt.Fprintf(verboseLogger.stdout, "--- %s ping statistics ---\n", verboseLogger.pinger.URL())
-
-	_, _ = fmt.Fprintf(verboseLogger.stdout, "%d requests sent, %d answers received, %.1f%% loss\n", attempts, successes, lossRate)
-
-	if successes > 0 {
-		_, _ = fmt.Fprintf(verboseLogger.stdout, "%s\n", pingStats.String())
+	verboseLogger.writeSummary(summary)
 
+	if successes := summary.Successes; successes > 0 {
 		verboseLogger.measureSum.TotalTime = verboseLogger.measureSum.TotalTime.Divide(successes)
 		verboseLogger.measureSum.ConnEstablishment = verboseLogger.measureSum.ConnEstablishment.Divide(successes)
 		verboseLogger.measureSum.DNSResolution = verboseLogger.measureSum.DNSResolution.Divide(successes)
 		verboseLogger.measureSum.TCPHandshake = verboseLogger.measureSum.TCPHandshake.Divide(successes)
 		verboseLogger.measureSum.TLSDuration = verboseLogger.measureSum.TLSDuration.Divide(successes)
+		verboseLogger.measureSum.StartTLSDuration = verboseLogger.measureSum.StartTLSDuration.Divide(successes)
+		verboseLogger.measureSum.ProxyConnection = verboseLogger.measureSum.ProxyConnection.Divide(successes)
+		verboseLogger.measureSum.ProxyTLSDuration = verboseLogger.measureSum.ProxyTLSDuration.Divide(successes)
+		verboseLogger.measureSum.ProxyTunnel = verboseLogger.measureSum.ProxyTunnel.Divide(successes)
+		verboseLogger.measureSum.SOCKSNegotiation = verboseLogger.measureSum.SOCKSNegotiation.Divide(successes)
+		verboseLogger.measureSum.WebSocketUpgrade = verboseLogger.measureSum.WebSocketUpgrade.Divide(successes)
 		verboseLogger.measureSum.RequestSending = verboseLogger.measureSum.RequestSending.Divide(successes)
 		verboseLogger.measureSum.Wait = verboseLogger.measureSum.Wait.Divide(successes)
 		verboseLogger.measureSum.ResponseIngesting = verboseLogger.measureSum.ResponseIngesting.Divide(successes)
 
 		verboseLogger.measureSum.TLSEnabled = verboseLogger.measureSum.TLSDuration > 0
 
+		if sum := verboseLogger.measureSum.Stream; sum != nil {
+			sum.TimeToFirstByte = sum.TimeToFirstByte.Divide(successes)
+			sum.TimeToFirstEvent = sum.TimeToFirstEvent.Divide(successes)
+			sum.Events /= successes
+			sum.GapAverage = sum.GapAverage.Divide(successes)
+
+			_, _ = fmt.Fprintf(verboseLogger.stdout, "\naverage stream timings (gap max is the overall maximum):\n")
+			writeStream(verboseLogger.stdout, sum)
+		}
+
 		_, _ = fmt.Fprintf(verboseLogger.stdout, "\naverage latency contributions:\n")
 
 		verboseLogger.drawMeasure(verboseLogger.measureSum, verboseLogger.stdout)
 	}
+
+	verboseLogger.writeExceeded(summary)
 }
 
 func (verboseLogger *verboseLogger) drawMeasure(measure *HTTPMeasure, stdout io.Writer) {
+	setup := []*measureEntry{
+		{label: "DNS resolution", duration: measure.DNSResolution},
+		{label: "TCP handshake", duration: measure.TCPHandshake},
+	}
+	if measure.UnixSocket {
+		setup[1] = &measureEntry{label: "Unix socket connection", duration: measure.TCPHandshake}
+	}
+	if measure.StartTLS != "" {
+		setup = append(setup, &measureEntry{label: fmt.Sprintf("STARTTLS (%s)", measure.StartTLS), duration: measure.StartTLSDuration})
+	}
+	if measure.Proxy != "" {
+		setup = []*measureEntry{
+			setup[0],
+			{label: fmt.Sprintf("proxy connection (%s)", measure.Proxy), duration: measure.ProxyConnection,
+				children: []*measureEntry{
+					setup[1],
+					{label: "TLS handshake", duration: measure.ProxyTLSDuration},
+				}},
+			{label: "SOCKS negotiation", duration: measure.SOCKSNegotiation},
+			{label: "CONNECT tunnel", duration: measure.ProxyTunnel},
+		}
+	}
+	if measure.TLSEnabled {
+		setup = append(setup, &measureEntry{label: "TLS handshake", duration: measure.TLSDuration})
+	}
+
 	entries := measureEntry{
 		label:    "request and response",
 		duration: measure.TotalTime,
 		children: []*measureEntry{
 			{label: "connection setup", duration: measure.ConnEstablishment,
-				children: []*measureEntry{
-					{label: "DNS resolution", duration: measure.DNSResolution},
-					{label: "TCP handshake", duration: measure.TCPHandshake},
-					{label: "TLS handshake", duration: measure.TLSDuration},
-				}},
+				children: setup},
 			{label: "request sending", duration: measure.RequestSending},
 			{label: "wait", duration: measure.Wait},
 			{label: "response ingestion", duration: measure.ResponseIngesting},
 		},
 	}
-	if !measure.TLSEnabled {
-		entries.children[0].children = entries.children[0].children[0:2]
+	if measure.Proto == protoTCP || measure.Proto == protoTLS {
+		entries = *entries.children[0]
 	}
 
-	l := verboseLogger.makeTreeList(&entries)
+	var l []measureEntryVisit
+
+	// the upgrade of WebSocket connections is shown apart from the round trip of the messages
+	if measure.Proto == protoWebSocket {
+		entries.label = "websocket upgrade"
+		entries.duration = measure.WebSocketUpgrade
+		entries.children = entries.children[:3]
+
+		l = append(verboseLogger.makeTreeList(&entries), verboseLogger.makeTreeList(&measureEntry{label: "message round trip", duration: measure.TotalTime})...)
+	} else {
+		l = verboseLogger.makeTreeList(&entries)
+	}
 
 	for i, e := range l {
 		pipes := make([]string, e.depth)
@@ -18,12 +18,20 @@ package app
 
 import (
 	"bytes"
+	"errors"
+	"fever.ch/http-ping/stats"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
-type PingerMock struct{}
+type PingerMock struct {
+	measure  HTTPMeasure
+	interval time.Duration
+}
 
 func TestHTTPPing(t *testing.T) {
 	b := bytes.NewBufferString("")
@@ -52,6 +60,247 @@ func TestHTTPPingVerbose(t *testing.T) {
 	}
 }
 
+func TestHTTPPingThroughput(t *testing.T) {
+	b := bytes.NewBufferString("")
+	instance, _ := NewHTTPPing(&Config{Count: 10, LogLevel: 1, Throughput: true}, b)
+	instance.(*httpPingImpl).pinger = &PingerMock{measure: HTTPMeasure{Bytes: 2000000, InBytes: 2100000, ResponseIngesting: stats.Measure(time.Second)}}
+	_ = instance.Run()
+
+	out, _ := ioutil.ReadAll(b)
+
+	if !strings.Contains(string(out), "rate=2.000 MB/s (wire=2.100 MB/s)") ||
+		!strings.Contains(string(out), "throughput min/avg/max = 2.000/2.000/2.000 MB/s") {
+		t.Fatal("Result didn't match expectations")
+	}
+}
+
+func TestHTTPPingTUI(t *testing.T) {
+	b := bytes.NewBufferString("")
+	instance, _ := NewHTTPPing(&Config{Count: 10, LogLevel: 3}, b)
+	instance.(*httpPingImpl).pinger = &PingerMock{measure: HTTPMeasure{StatusCode: 200, TotalTime: stats.Measure(10 * time.Millisecond),
+		Wait: stats.Measure(8 * time.Millisecond), RequestSending: stats.Measure(2 * time.Millisecond)}}
+	_ = instance.Run()
+
+	out, _ := ioutil.ReadAll(b)
+
+	if strings.Count(string(out), ansiClear) != 10 ||
+		!strings.Contains(string(out), "p50/p90/p95/p99 = 10.0/10.0/10.0/10.0 ms") ||
+		!strings.Contains(string(out), "  200         10 100.0%") ||
+		!strings.Contains(string(out), "10 requests sent, 10 answers received, 0.0% loss") {
+		t.Fatal("Result didn't match expectations")
+	}
+}
+
+func TestHTTPPingHistogram(t *testing.T) {
+	b := bytes.NewBufferString("")
+	instance, _ := NewHTTPPing(&Config{Count: 10, LogLevel: 0, Histogram: true, HistogramPhases: true}, b)
+	instance.(*httpPingImpl).pinger = &PingerMock{measure: HTTPMeasure{TotalTime: stats.Measure(10 * time.Millisecond),
+		Wait: stats.Measure(8 * time.Millisecond)}}
+	_ = instance.Run()
+
+	out, _ := ioutil.ReadAll(b)
+
+	if !strings.Contains(string(out), "latency histogram:\n  10.000 -   10.000 ms │"+strings.Repeat("█", histogramWidth)+" 10\n") ||
+		!strings.Contains(string(out), "wait histogram:") || strings.Contains(string(out), "dns histogram:") ||
+		!strings.Contains(string(out), "latency heatmap:\n  10.000 ms │") {
+		t.Fatalf("Result didn't match expectations: %s", out)
+	}
+}
+
+func TestHTTPPingSummaryInterval(t *testing.T) {
+	b := bytes.NewBufferString("")
+	instance, _ := NewHTTPPing(&Config{Count: 10, LogLevel: 0, SummaryInterval: 20 * time.Millisecond}, b)
+	instance.(*httpPingImpl).pinger = &PingerMock{measure: HTTPMeasure{TotalTime: stats.Measure(10 * time.Millisecond)},
+		interval: 10 * time.Millisecond}
+	_ = instance.Run()
+
+	out, _ := ioutil.ReadAll(b)
+
+	if !strings.Contains(string(out), "answers received, 0.0% loss\n          round-trip min/avg/max/stddev = 10.000/10.000/10.000/0.000 ms\n"+
+		"          percentiles p50/p90/p95/p99 = 10.000/10.000/10.000/10.000 ms\n") {
+		t.Fatalf("Result didn't match expectations: %s", out)
+	}
+}
+
+func TestHTTPPingThresholds(t *testing.T) {
+	b := bytes.NewBufferString("")
+	instance, _ := NewHTTPPing(&Config{Count: 10, LogLevel: 0,
+		Thresholds: []Threshold{{Statistic: "loss", Max: 0}, {Statistic: "p95", Max: 5}, {Statistic: "avg", Max: 20}}}, b)
+	instance.(*httpPingImpl).pinger = &PingerMock{measure: HTTPMeasure{TotalTime: stats.Measure(10 * time.Millisecond)}}
+	err := instance.Run()
+
+	out, _ := ioutil.ReadAll(b)
+
+	var exitError *ExitError
+	if !errors.As(err, &exitError) || exitError.Code != 1 ||
+		!strings.Contains(string(out), "threshold exceeded: p95 = 10.0 ms > 5.0 ms\n") ||
+		strings.Contains(string(out), "loss =") || strings.Contains(string(out), "avg =") {
+		t.Fatalf("Result didn't match expectations: %s", out)
+	}
+
+	instance, _ = NewHTTPPing(&Config{Count: 10, LogLevel: 0, Thresholds: []Threshold{{Statistic: "loss", Max: 5}}}, b)
+	instance.(*httpPingImpl).pinger = &PingerMock{measure: HTTPMeasure{IsFailure: true}}
+
+	if err = instance.Run(); !errors.As(err, &exitError) || exitError.Reason != "no answer received" ||
+		!strings.Contains(b.String(), "threshold exceeded: loss = 100.0% > 5.0%\n") {
+		t.Fatalf("Run without answer should have failed: %v", err)
+	}
+}
+
+// observerMock records the events it's notified of
+type observerMock struct {
+	events  []string
+	summary *Summary
+}
+
+func (observerMock *observerMock) OnStart(url string, method string) {
+	observerMock.events = append(observerMock.events, "start "+url+" "+method)
+}
+
+func (observerMock *observerMock) OnMeasure(_ *HTTPMeasure, _ int) {
+	observerMock.events = append(observerMock.events, "measure")
+}
+
+func (observerMock *observerMock) OnRedirect(url string) {
+	observerMock.events = append(observerMock.events, "redirect "+url)
+}
+
+func (observerMock *observerMock) OnClose(summary *Summary) {
+	observerMock.events = append(observerMock.events, "close")
+	observerMock.summary = summary
+}
+
+func TestHTTPPingObservers(t *testing.T) {
+	b := bytes.NewBufferString("")
+	observer := &observerMock{}
+	var jsonl bytes.Buffer
+
+	instance, _ := NewHTTPPingWithObservers(&Config{Count: 10, Method: "GET"}, b, observer, NewJSONLObserver(&jsonl))
+	instance.(*httpPingImpl).pinger = &PingerMock{measure: HTTPMeasure{StatusCode: 200, TotalTime: stats.Measure(10 * time.Millisecond),
+		DNSResolution: stats.MeasureNotValid}}
+	_ = instance.Run()
+
+	if len(observer.events) != 12 || observer.events[0] != "start https://www.google.com GET" || observer.events[11] != "close" ||
+		observer.summary.Successes != 10 || observer.summary.Percentiles[2] != stats.Measure(10*time.Millisecond) {
+		t.Fatalf("Observer wasn't notified as expected: %v", observer.events)
+	}
+
+	if b.Len() != 0 {
+		t.Errorf("Nothing should have been printed without logger: %s", b.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(jsonl.String()), "\n")
+	if len(lines) != 12 || !strings.Contains(lines[1], `"status_code":200`) || !strings.Contains(lines[1], `"total_ms":10,`) ||
+		strings.Contains(lines[1], `"dns_ms"`) || !strings.Contains(lines[11], `"p95_ms":10,`) {
+		t.Fatalf("JSON lines didn't match expectations: %s", jsonl.String())
+	}
+}
+
+func TestHTTPPingObserversRedirect(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/target", http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	observer := &observerMock{}
+	instance, _ := NewHTTPPingWithObservers(&Config{Target: ts.URL, Count: 2, Method: "GET", FollowRedirects: true}, ioutil.Discard, observer)
+	_ = instance.Run()
+
+	// the redirection followed by the warm-up request is notified once the run has started
+	if len(observer.events) != 5 || observer.events[0] != "start "+ts.URL+" GET" || !strings.HasPrefix(observer.events[1], "redirect ") {
+		t.Fatalf("Observer wasn't notified as expected: %v", observer.events)
+	}
+}
+
+func TestHTTPPingCorrectedPercentiles(t *testing.T) {
+	b := bytes.NewBufferString("")
+	instance, _ := NewHTTPPing(&Config{Count: 10, LogLevel: 0, Interval: 10 * time.Millisecond, CorrectOmission: true}, b)
+	instance.(*httpPingImpl).pinger = &PingerMock{measure: HTTPMeasure{TotalTime: stats.Measure(35 * time.Millisecond)}}
+	_ = instance.Run()
+
+	// each measure adds the latencies of the requests which should have been sent meanwhile (25 and 15 ms)
+	if !strings.Contains(b.String(), "percentiles p50/p90/p95/p99 = 35.000/35.000/35.000/35.000 ms\n"+
+		"corrected percentiles p50/p90/p95/p99 = 25.000/35.000/35.000/35.000 ms (coordinated omission)\n") {
+		t.Fatalf("Result didn't match expectations: %s", b.String())
+	}
+}
+
+// measuresPingerMock delivers its measures in order
+type measuresPingerMock struct {
+	measures []*HTTPMeasure
+}
+
+func (pingerMock *measuresPingerMock) Ping() <-chan *HTTPMeasure {
+	measures := make(chan *HTTPMeasure)
+	go func() {
+		defer close(measures)
+		for _, measure := range pingerMock.measures {
+			measures <- measure
+		}
+	}()
+	return measures
+}
+
+func (pingerMock *measuresPingerMock) URL() string {
+	return "https://www.google.com"
+}
+
+func TestHTTPPingCorrectedPercentilesSaturated(t *testing.T) {
+	start := time.Now()
+	slot := func(i int) time.Time {
+		return start.Add(time.Duration(i) * 10 * time.Millisecond)
+	}
+
+	// requests of 10 ms on schedule, then 2 slots skipped as all the clients were busy
+	pinger := &measuresPingerMock{}
+	for i := 0; i < 13; i++ {
+		if i == 10 || i == 11 {
+			pinger.measures = append(pinger.measures, &HTTPMeasure{Skipped: true, IntendedStart: slot(i)})
+		} else {
+			pinger.measures = append(pinger.measures, &HTTPMeasure{TotalTime: stats.Measure(10 * time.Millisecond), IntendedStart: slot(i), Start: slot(i)})
+		}
+	}
+
+	b := bytes.NewBufferString("")
+	instance, _ := NewHTTPPing(&Config{Count: 13, LogLevel: 0, Interval: 10 * time.Millisecond, FixedRate: true, MaxInFlight: 2, CorrectOmission: true}, b)
+	instance.(*httpPingImpl).pinger = pinger
+	_ = instance.Run()
+
+	// the skipped slots would have waited for the next request (20 and 10 ms) before lasting as long
+	if !strings.Contains(b.String(), "percentiles p50/p90/p95/p99 = 10.000/10.000/10.000/10.000 ms\n"+
+		"corrected percentiles p50/p90/p95/p99 = 10.000/20.000/30.000/30.000 ms (coordinated omission)\n") {
+		t.Fatalf("Result didn't match expectations: %s", b.String())
+	}
+
+	// the request following the skipped slots fails, they would have waited for it nonetheless
+	pinger.measures[12] = &HTTPMeasure{IsFailure: true, FailureCause: "Timeout", IntendedStart: slot(12), Start: slot(12)}
+	pinger.measures = append(pinger.measures, &HTTPMeasure{TotalTime: stats.Measure(10 * time.Millisecond), IntendedStart: slot(13), Start: slot(13)})
+
+	b = bytes.NewBufferString("")
+	instance, _ = NewHTTPPing(&Config{Count: 14, LogLevel: 0, Interval: 10 * time.Millisecond, FixedRate: true, MaxInFlight: 2, CorrectOmission: true}, b)
+	instance.(*httpPingImpl).pinger = pinger
+	_ = instance.Run()
+
+	if !strings.Contains(b.String(), "corrected percentiles p50/p90/p95/p99 = 10.000/10.000/20.000/20.000 ms (coordinated omission)\n") {
+		t.Fatalf("Result didn't match expectations: %s", b.String())
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	latencies := []stats.Measure{10, 80, stats.MeasureNotValid, 45}
+	if got := sparkline(latencies); got != "▁█×▄" {
+		t.Errorf("Sparkline was incorrect, got: %s", got)
+	}
+
+	// spreads of seconds would overflow 32-bit integers
+	latencies = []stats.Measure{stats.Measure(time.Millisecond), stats.Measure(2 * time.Second), stats.Measure(time.Second)}
+	if got := sparkline(latencies); got != "▁█▄" {
+		t.Errorf("Sparkline of a large spread was incorrect, got: %s", got)
+	}
+}
+
 func (pingerMock *PingerMock) URL() string {
 	return "https://www.google.com"
 }
@@ -62,7 +311,9 @@ func (pingerMock *PingerMock) Ping() <-chan *HTTPMeasure {
 	go func() {
 		defer close(measures)
 		for i := 0; i < 10; i++ {
-			measures <- &HTTPMeasure{}
+			measure := pingerMock.measure
+			measures <- &measure
+			time.Sleep(pingerMock.interval)
 		}
 	}()
 
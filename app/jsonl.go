@@ -0,0 +1,180 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"encoding/json"
+	"fever.ch/http-ping/stats"
+	"io"
+	"os"
+	"time"
+)
+
+// jsonlObserver writes one JSON object per line for each event of the run
+type jsonlObserver struct {
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+type jsonlStart struct {
+	Event  string    `json:"event"`
+	Time   time.Time `json:"time"`
+	URL    string    `json:"url"`
+	Method string    `json:"method,omitempty"`
+}
+
+type jsonlMeasure struct {
+	Event        string    `json:"event"`
+	Time         time.Time `json:"time"`
+	ID           int       `json:"id"`
+	Failure      bool      `json:"failure"`
+	FailureCause string    `json:"failure_cause,omitempty"`
+	Proto        string    `json:"proto,omitempty"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	RemoteAddr   string    `json:"remote_addr,omitempty"`
+	SocketReused bool      `json:"socket_reused"`
+	Bytes        int64     `json:"bytes"`
+	InBytes      int64     `json:"in_bytes"`
+	OutBytes     int64     `json:"out_bytes"`
+
+	Total             *float64 `json:"total_ms,omitempty"`
+	DNSResolution     *float64 `json:"dns_ms,omitempty"`
+	TCPHandshake      *float64 `json:"tcp_ms,omitempty"`
+	TLSDuration       *float64 `json:"tls_ms,omitempty"`
+	ConnEstablishment *float64 `json:"connection_ms,omitempty"`
+	RequestSending    *float64 `json:"request_ms,omitempty"`
+	Wait              *float64 `json:"wait_ms,omitempty"`
+	ResponseIngesting *float64 `json:"response_ms,omitempty"`
+}
+
+type jsonlRedirect struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	URL   string    `json:"url"`
+}
+
+type jsonlClose struct {
+	Event     string    `json:"event"`
+	Time      time.Time `json:"time"`
+	URL       string    `json:"url"`
+	Attempts  int64     `json:"attempts"`
+	Successes int64     `json:"successes"`
+	Skipped   int64     `json:"skipped,omitempty"`
+	LossRate  float64   `json:"loss_percent"`
+	Min       *float64  `json:"min_ms,omitempty"`
+	Average   *float64  `json:"avg_ms,omitempty"`
+	Max       *float64  `json:"max_ms,omitempty"`
+	StdDev    *float64  `json:"stddev_ms,omitempty"`
+	P50       *float64  `json:"p50_ms,omitempty"`
+	P90       *float64  `json:"p90_ms,omitempty"`
+	P95       *float64  `json:"p95_ms,omitempty"`
+	P99       *float64  `json:"p99_ms,omitempty"`
+
+	CorrectedP50 *float64 `json:"corrected_p50_ms,omitempty"`
+	CorrectedP90 *float64 `json:"corrected_p90_ms,omitempty"`
+	CorrectedP95 *float64 `json:"corrected_p95_ms,omitempty"`
+	CorrectedP99 *float64 `json:"corrected_p99_ms,omitempty"`
+
+	Exceeded []string `json:"exceeded,omitempty"`
+}
+
+// NewJSONLObserver builds an observer writing each event of the run as a JSON object on its own line (JSON Lines),
+// durations are in milliseconds and missing ones are omitted
+func NewJSONLObserver(w io.Writer) Observer {
+	return &jsonlObserver{encoder: newJSONLEncoder(w)}
+}
+
+func newJSONLEncoder(w io.Writer) *json.Encoder {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return encoder
+}
+
+// newJSONLFileObserver builds a JSON Lines observer writing to a file, which is closed at the end of the run
+func newJSONLFileObserver(path string) (*jsonlObserver, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlObserver{encoder: newJSONLEncoder(file), closer: file}, nil
+}
+
+// milliseconds returns a measure in milliseconds, nil if it's not valid
+func milliseconds(m stats.Measure) *float64 {
+	if !m.IsValid() {
+		return nil
+	}
+	ms := m.ToFloat(time.Millisecond)
+	return &ms
+}
+
+func (jsonl *jsonlObserver) OnStart(url string, method string) {
+	_ = jsonl.encoder.Encode(&jsonlStart{Event: "start", Time: time.Now(), URL: url, Method: method})
+}
+
+func (jsonl *jsonlObserver) OnMeasure(measure *HTTPMeasure, id int) {
+	record := &jsonlMeasure{Event: "measure", Time: time.Now(), ID: id, Failure: measure.IsFailure, FailureCause: measure.FailureCause}
+	if !measure.IsFailure {
+		record.Proto = measure.Proto
+		record.StatusCode = measure.StatusCode
+		record.RemoteAddr = measure.RemoteAddr
+		record.SocketReused = measure.SocketReused
+		record.Bytes = measure.Bytes
+		record.InBytes = measure.InBytes
+		record.OutBytes = measure.OutBytes
+
+		record.Total = milliseconds(measure.TotalTime)
+		record.DNSResolution = milliseconds(measure.DNSResolution)
+		record.TCPHandshake = milliseconds(measure.TCPHandshake)
+		record.TLSDuration = milliseconds(measure.TLSDuration)
+		record.ConnEstablishment = milliseconds(measure.ConnEstablishment)
+		record.RequestSending = milliseconds(measure.RequestSending)
+		record.Wait = milliseconds(measure.Wait)
+		record.ResponseIngesting = milliseconds(measure.ResponseIngesting)
+	}
+	_ = jsonl.encoder.Encode(record)
+}
+
+func (jsonl *jsonlObserver) OnRedirect(url string) {
+	_ = jsonl.encoder.Encode(&jsonlRedirect{Event: "redirect", Time: time.Now(), URL: url})
+}
+
+func (jsonl *jsonlObserver) OnClose(summary *Summary) {
+	record := &jsonlClose{Event: "close", Time: time.Now(), URL: summary.URL, Attempts: summary.Attempts,
+		Successes: summary.Successes, Skipped: summary.Skipped, LossRate: summary.LossRate, Exceeded: summary.Exceeded}
+	if summary.Successes > 0 {
+		record.Min = milliseconds(summary.PingStats.Min)
+		record.Average = milliseconds(summary.PingStats.Average)
+		record.Max = milliseconds(summary.PingStats.Max)
+		record.StdDev = milliseconds(summary.PingStats.StdDev)
+		record.P50 = milliseconds(summary.Percentiles[0])
+		record.P90 = milliseconds(summary.Percentiles[1])
+		record.P95 = milliseconds(summary.Percentiles[2])
+		record.P99 = milliseconds(summary.Percentiles[3])
+		if corrected := summary.CorrectedPercentiles; corrected != nil {
+			record.CorrectedP50 = milliseconds(corrected[0])
+			record.CorrectedP90 = milliseconds(corrected[1])
+			record.CorrectedP95 = milliseconds(corrected[2])
+			record.CorrectedP99 = milliseconds(corrected[3])
+		}
+	}
+	_ = jsonl.encoder.Encode(record)
+
+	if jsonl.closer != nil {
+		_ = jsonl.closer.Close()
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fever.ch/http-ping/stats"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// loadLogger prints the achieved rate, the errors and the latency percentiles of each window of time of a load run,
+// then the statistics of the whole run with the errors by cause
+type loadLogger struct {
+	textLogger
+	window time.Duration
+	start  time.Time
+
+	current   int
+	requests  int64
+	errors    int64
+	latencies []stats.Measure
+	causes    map[string]int64
+}
+
+func newLoadLogger(config *Config, stdout io.Writer) Observer {
+	window := config.LoadWindow
+	if window <= 0 {
+		window = time.Second
+	}
+	return &loadLogger{textLogger: textLogger{config: config, stdout: stdout}, window: window, causes: make(map[string]int64)}
+}
+
+func (loadLogger *loadLogger) OnStart(url string, method string) {
+	loadLogger.textLogger.OnStart(url, method)
+	_, _ = fmt.Fprintf(loadLogger.stdout, "load: target rate %.1f requests/s, up to %d clients\n\n", loadLogger.config.LoadRate, loadLogger.config.LoadClients)
+	loadLogger.start = time.Now()
+}
+
+func (loadLogger *loadLogger) OnMeasure(measure *HTTPMeasure, _ int) {
+	loadLogger.flushUntil(time.Since(loadLogger.start))
+
+	loadLogger.requests++
+	if measure.IsFailure {
+		loadLogger.errors++
+		loadLogger.causes[measure.FailureCause]++
+	} else {
+		loadLogger.latencies = append(loadLogger.latencies, measure.TotalTime)
+	}
+}
+
+// flushUntil prints the windows elapsed until the given time since the start, the windows without any completed request
+// are printed as well, they show the stalls
+func (loadLogger *loadLogger) flushUntil(elapsed time.Duration) {
+	for window := int(elapsed / loadLogger.window); loadLogger.current < window; loadLogger.current++ {
+		loadLogger.flush(loadLogger.window)
+	}
+}
+
+// flush prints the statistics of the current window, which lasted the duration, (unless the output is quiet) and
+// resets them
+func (loadLogger *loadLogger) flush(duration time.Duration) {
+	if loadLogger.config.LogLevel > 0 {
+		end := time.Duration(loadLogger.current)*loadLogger.window + duration
+		_, _ = fmt.Fprintf(loadLogger.stdout, "%8s: %7.1f requests/s, %5.1f%% errors", end.Truncate(time.Millisecond), float64(loadLogger.requests)/duration.Seconds(),
+			lossRate(int(loadLogger.requests), int(loadLogger.requests-loadLogger.errors)))
+		if len(loadLogger.latencies) > 0 {
+			_, _ = fmt.Fprintf(loadLogger.stdout, ", p50/p90/p99 = %s ms", formatPercentiles(stats.Percentiles(loadLogger.latencies, 50, 90, 99)))
+		}
+		_, _ = fmt.Fprintf(loadLogger.stdout, "\n")
+	}
+
+	loadLogger.requests = 0
+	loadLogger.errors = 0
+	loadLogger.latencies = nil
+}
+
+func (loadLogger *loadLogger) OnClose(summary *Summary) {
+	elapsed := time.Since(loadLogger.start)
+
+	// the windows elapsed since the last completed request are printed up to the end of the run, the last one is only
+	// partially elapsed
+	loadLogger.flushUntil(elapsed)
+	if loadLogger.requests > 0 {
+		loadLogger.flush(elapsed - time.Duration(loadLogger.current)*loadLogger.window)
+	}
+
+	_, _ = fmt.Fprintf(loadLogger.stdout, "\n")
+	loadLogger.writeSummary(summary)
+
+	_, _ = fmt.Fprintf(loadLogger.stdout, "load: target rate %.1f requests/s, achieved %.1f requests/s over %s\n",
+		loadLogger.config.LoadRate, float64(summary.Attempts)/elapsed.Seconds(), elapsed.Truncate(time.Millisecond))
+
+	if len(loadLogger.causes) > 0 {
+		var causes []string
+		for cause := range loadLogger.causes {
+			causes = append(causes, cause)
+		}
+		sort.Slice(causes, func(i, j int) bool {
+			if loadLogger.causes[causes[i]] != loadLogger.causes[causes[j]] {
+				return loadLogger.causes[causes[i]] > loadLogger.causes[causes[j]]
+			}
+			return causes[i] < causes[j]
+		})
+
+		_, _ = fmt.Fprintf(loadLogger.stdout, "errors by cause:\n")
+		for _, cause := range causes {
+			_, _ = fmt.Fprintf(loadLogger.stdout, "%8d: %s\n", loadLogger.causes[cause], cause)
+		}
+	}
+
+	loadLogger.writeExceeded(summary)
+}
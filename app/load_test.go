@@ -0,0 +1,110 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"fever.ch/http-ping/stats"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadLogger(t *testing.T) {
+	var b bytes.Buffer
+	logger := newLoadLogger(&Config{LogLevel: 1, LoadRate: 100, LoadClients: 2, LoadWindow: 50 * time.Millisecond}, &b)
+
+	logger.OnStart("http://localhost", "GET")
+	logger.OnMeasure(&HTTPMeasure{TotalTime: stats.Measure(10 * time.Millisecond)}, 0)
+	logger.OnMeasure(&HTTPMeasure{IsFailure: true, FailureCause: "boom"}, 1)
+	time.Sleep(120 * time.Millisecond)
+	logger.OnMeasure(&HTTPMeasure{IsFailure: true, FailureCause: "boom"}, 2)
+	logger.OnMeasure(&HTTPMeasure{IsFailure: true, FailureCause: "bang"}, 3)
+	logger.OnClose(&Summary{URL: "http://localhost", Attempts: 4, Successes: 1, LossRate: 75,
+		PingStats: stats.PingStatsFromLatencies([]stats.Measure{stats.Measure(10 * time.Millisecond)})})
+
+	out := b.String()
+	if !strings.Contains(out, "    50ms:    40.0 requests/s,  50.0% errors, p50/p90/p99 = 10.000/10.000/10.000 ms\n") ||
+		!strings.Contains(out, "   100ms:     0.0 requests/s,   0.0% errors\n") ||
+		!strings.Contains(out, "load: target rate 100.0 requests/s, achieved ") ||
+		!strings.Contains(out, "errors by cause:\n       2: boom\n       1: bang\n") {
+		t.Fatalf("Result didn't match expectations: %s", out)
+	}
+
+	// the windows without any completed request are printed until the end of the run
+	b.Reset()
+	logger = newLoadLogger(&Config{LogLevel: 1, LoadRate: 100, LoadClients: 2, LoadWindow: 50 * time.Millisecond}, &b)
+
+	logger.OnStart("http://localhost", "GET")
+	logger.OnMeasure(&HTTPMeasure{TotalTime: stats.Measure(10 * time.Millisecond)}, 0)
+	time.Sleep(120 * time.Millisecond)
+	logger.OnClose(&Summary{URL: "http://localhost", Attempts: 1, Successes: 1,
+		PingStats: stats.PingStatsFromLatencies([]stats.Measure{stats.Measure(10 * time.Millisecond)})})
+
+	out = b.String()
+	if !strings.Contains(out, "    50ms:    20.0 requests/s,   0.0% errors, p50/p90/p99 = 10.000/10.000/10.000 ms\n") ||
+		!strings.Contains(out, "   100ms:     0.0 requests/s,   0.0% errors\n") {
+		t.Fatalf("Trailing windows should have been printed: %s", out)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(20 * time.Millisecond)
+			_, _ = w.Write([]byte("Hello"))
+		}))
+	defer ts.Close()
+
+	var b bytes.Buffer
+	instance, _ := NewHTTPPing(&Config{Target: ts.URL, Method: "GET", Count: 40, LogLevel: 1, LoadRate: 200, LoadClients: 16}, &b)
+
+	start := time.Now()
+	if err := instance.Run(); err != nil {
+		t.Fatalf("Load run should have succeed: %s", err)
+	}
+
+	// 40 requests at 200 requests/s last 200 ms, with up to 8 requests of 20 ms in flight (the first request of each
+	// client comes after its warm-up request)
+	if time.Since(start) > time.Second || !strings.Contains(b.String(), "40 requests sent, 40 answers received, 0.0% loss\n") {
+		t.Fatalf("Result didn't match expectations (%s): %s", time.Since(start), b.String())
+	}
+}
+
+func TestLoadCorrectedPercentiles(t *testing.T) {
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(50 * time.Millisecond)
+			_, _ = w.Write([]byte("Hello"))
+		}))
+	defer ts.Close()
+
+	// requests of 50 ms every 10 ms need 5 clients, the slots which can't be served by 2 clients are skipped and wait
+	// for up to 40 ms
+	observer := &observerMock{}
+	instance, _ := NewHTTPPingWithObservers(&Config{Target: ts.URL, Method: "GET", Count: 10, LoadRate: 100, LoadClients: 2,
+		LoadWindow: time.Second, CorrectOmission: true}, &bytes.Buffer{}, observer)
+	_ = instance.Run()
+
+	summary := observer.summary
+	if summary.Skipped == 0 || summary.CorrectedPercentiles[3] < summary.Percentiles[3]+stats.Measure(20*time.Millisecond) {
+		t.Fatalf("skipped slots should have raised the corrected p99 (%d skipped, p99 = %s, corrected p99 = %s)", summary.Skipped,
+			time.Duration(summary.Percentiles[3]), time.Duration(summary.CorrectedPercentiles[3]))
+	}
+}
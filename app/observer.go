@@ -0,0 +1,106 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fever.ch/http-ping/stats"
+	"sync"
+)
+
+// Observer is notified of the progress of a run, each output (text on the terminal, JSON lines, metrics...) is an
+// observer and several of them can be registered at once with NewHTTPPingWithObservers
+type Observer interface {
+	// OnStart is called before the first request, method is empty if the target isn't an HTTP one
+	OnStart(url string, method string)
+
+	// OnMeasure is called for each measure, id is its sequence number
+	OnMeasure(measure *HTTPMeasure, id int)
+
+	// OnRedirect is called when a redirection is followed
+	OnRedirect(url string)
+
+	// OnClose is called once the run is over
+	OnClose(summary *Summary)
+}
+
+// Summary is the outcome of a run
+type Summary struct {
+	URL       string
+	Attempts  int64
+	Successes int64
+	LossRate  float64
+
+	// Skipped is the number of requests which have not been sent with a fixed rate, as too many were in flight
+	Skipped int64
+
+	// PingStats and Percentiles (p50, p90, p95, p99) are only meaningful if there's at least one success
+	PingStats   *stats.PingStats
+	Percentiles []stats.Measure
+
+	// CorrectedPercentiles are the percentiles corrected for coordinated omission, nil unless they're required
+	CorrectedPercentiles []stats.Measure
+
+	// Download and Upload are only computed when the throughput is reported, nil if there's no transfer
+	Download *stats.RateStats
+	Upload   *stats.RateStats
+
+	// Exceeded describes the thresholds which have been exceeded
+	Exceeded []string
+}
+
+// observers notifies each observer in turn, the notifications are serialized as the redirections are notified by the
+// pinger while the other events are notified by the run
+type observers struct {
+	mutex sync.Mutex
+	list  []Observer
+}
+
+func (observers *observers) OnStart(url string, method string) {
+	observers.mutex.Lock()
+	defer observers.mutex.Unlock()
+
+	for _, observer := range observers.list {
+		observer.OnStart(url, method)
+	}
+}
+
+func (observers *observers) OnMeasure(measure *HTTPMeasure, id int) {
+	observers.mutex.Lock()
+	defer observers.mutex.Unlock()
+
+	for _, observer := range observers.list {
+		observer.OnMeasure(measure, id)
+	}
+}
+
+func (observers *observers) OnRedirect(url string) {
+	observers.mutex.Lock()
+	defer observers.mutex.Unlock()
+
+	for _, observer := range observers.list {
+		observer.OnRedirect(url)
+	}
+}
+
+func (observers *observers) OnClose(summary *Summary) {
+	observers.mutex.Lock()
+	defer observers.mutex.Unlock()
+
+	for _, observer := range observers.list {
+		observer.OnClose(summary)
+	}
+}
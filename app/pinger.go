@@ -17,9 +17,14 @@
 package app
 
 import (
+	"context"
+	"crypto/x509"
+	"fever.ch/http-ping/net/sockettrace"
 	"fever.ch/http-ping/stats"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -29,13 +34,38 @@ type HTTPMeasure struct {
 
 	StatusCode   int
 	Bytes        int64
+	Truncated    bool
 	InBytes      int64
 	OutBytes     int64
+	RequestBytes int64
+	SentBytes    int64
 	SocketReused bool
 	Compressed   bool
 	RemoteAddr   string
+	UnixSocket   bool
 	TLSEnabled   bool
 	TLSVersion   string
+	TCPInfo      *sockettrace.TCPInfo
+	Stream       *StreamStats
+
+	TLSCipherSuite  string
+	TLSALPN         string
+	TLSCertificates []*x509.Certificate
+
+	GRPCStatus    string
+	ServingStatus string
+
+	WebSocketMessage string
+	WebSocketUpgrade stats.Measure
+
+	StartTLS         string
+	StartTLSDuration stats.Measure
+
+	Proxy            string
+	ProxyConnection  stats.Measure
+	ProxyTLSDuration stats.Measure
+	ProxyTunnel      stats.Measure
+	SOCKSNegotiation stats.Measure
 
 	TotalTime         stats.Measure
 	DNSResolution     stats.Measure
@@ -49,6 +79,46 @@ type HTTPMeasure struct {
 	IsFailure    bool
 	FailureCause string
 	Headers      *http.Header
+
+	// IntendedStart is when the request should have been sent according to the schedule, Start is when it was sent
+	IntendedStart time.Time
+	Start         time.Time
+
+	// Skipped is true if no request has been sent as too many of them were in flight at the time (fixed rate)
+	Skipped bool
+}
+
+// DownloadRate returns the rate at which the body of the response has been received (in bytes per second), 0 if it
+// cannot be computed
+func (measure *HTTPMeasure) DownloadRate() float64 {
+	return transferRate(measure.Bytes, measure.ResponseIngesting)
+}
+
+// WireDownloadRate returns the rate at which bytes have been read from the network while the response was received
+// (in bytes per second), 0 if it cannot be computed
+func (measure *HTTPMeasure) WireDownloadRate() float64 {
+	return transferRate(measure.InBytes, measure.ResponseIngesting)
+}
+
+// UploadRate returns the rate at which bytes have been written to the network while the request was sent (in bytes
+// per second), 0 if it cannot be computed
+func (measure *HTTPMeasure) UploadRate() float64 {
+	return transferRate(measure.SentBytes, measure.RequestSending)
+}
+
+// ScheduleDelay returns how late the request has been sent compared to the schedule, 0 if it's unknown
+func (measure *HTTPMeasure) ScheduleDelay() stats.Measure {
+	if measure.IntendedStart.IsZero() || measure.Start.Before(measure.IntendedStart) {
+		return 0
+	}
+	return stats.Measure(measure.Start.Sub(measure.IntendedStart))
+}
+
+func transferRate(bytes int64, duration stats.Measure) float64 {
+	if !duration.IsValid() || duration <= 0 {
+		return 0
+	}
+	return float64(bytes) / time.Duration(duration).Seconds()
 }
 
 // Pinger does the calls to the actual HTTP/S component
@@ -58,9 +128,19 @@ type Pinger interface {
 	URL() string
 }
 
+// ContextPinger is a Pinger which can be stopped, the pinger of this package implements it
+type ContextPinger interface {
+	Pinger
+
+	// PingContext does the same as Ping until ctx is canceled, the channel of measures is closed then
+	PingContext(ctx context.Context) <-chan *HTTPMeasure
+}
+
 type pingerImpl struct {
-	client WebClient
-	config *Config
+	client    WebClient
+	newClient func() (WebClient, error)
+	config    *Config
+	random    *rand.Rand
 }
 
 // NewPinger builds a new pingerImpl
@@ -70,36 +150,253 @@ func NewPinger(config *Config, runtimeConfig *RuntimeConfig) (Pinger, error) {
 
 	pinger.config = config
 
-	client, err := NewWebClient(config, runtimeConfig)
+	client, err := newClient(config, runtimeConfig)
 	if err != nil {
-		return nil, fmt.Errorf("%s (%s)", err, config.IPProtocol)
+		return nil, err
 	}
 
 	pinger.client = client
+	pinger.newClient = func() (WebClient, error) {
+		return newClient(config, runtimeConfig)
+	}
+	pinger.random = rand.New(rand.NewSource(time.Now().UnixNano()))
 
 	return &pinger, nil
 }
 
+// newClient builds the client matching the scheme of the target
+func newClient(config *Config, runtimeConfig *RuntimeConfig) (WebClient, error) {
+	var client WebClient
+	var err error
+
+	if isTCPTarget(config.Target) {
+		client, err = NewTCPClient(config, runtimeConfig)
+	} else if isTLSTarget(config.Target) {
+		client, err = NewTLSClient(config, runtimeConfig)
+	} else if isGRPCTarget(config.Target) {
+		client, err = NewGRPCClient(config, runtimeConfig)
+	} else if isWebSocketTarget(config.Target) {
+		client, err = NewWebSocketClient(config, runtimeConfig)
+	} else {
+		client, err = NewWebClient(config, runtimeConfig)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s (%s)", err, config.IPProtocol)
+	}
+	return client, nil
+}
+
 func (pinger *pingerImpl) URL() string {
 	return pinger.client.URL()
 }
 
 // Ping actually does the pinging specified in config
 func (pinger *pingerImpl) Ping() <-chan *HTTPMeasure {
+	return pinger.PingContext(context.Background())
+}
+
+// PingContext does the pinging specified in config until ctx is canceled, the channel of measures is closed then
+func (pinger *pingerImpl) PingContext(ctx context.Context) <-chan *HTTPMeasure {
 	measures := make(chan *HTTPMeasure)
 	go func() {
 		defer close(measures)
 
-		if !pinger.config.DisableKeepAlive || pinger.config.FollowRedirects {
-			pinger.client.DoMeasure(pinger.config.FollowRedirects)
-			time.Sleep(pinger.config.Interval)
+		if end := pinger.end(time.Now()); !end.IsZero() {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, end)
+			defer cancel()
 		}
 
-		for a := int64(0); a < pinger.config.Count; a++ {
-			measures <- pinger.client.DoMeasure(false)
-			time.Sleep(pinger.config.Interval)
+		if pinger.warmUp(ctx, pinger.client) && !pinger.sleep(ctx) {
+			return
+		}
+
+		// the load mode is an open model: requests are started at the target rate whatever the latency is
+		if pinger.config.FixedRate || pinger.config.LoadRate > 0 {
+			pinger.pingAtFixedRate(ctx, measures)
+			return
+		}
+
+		for a := int64(0); a < pinger.config.Count && ctx.Err() == nil; a++ {
+			// each request is sent as soon as the interval after the previous one is over
+			start := time.Now()
+			measure := doMeasure(ctx, pinger.client, false)
+			measure.IntendedStart, measure.Start = start, start
+
+			// a measure interrupted by the end of the run is neither a success nor a failure
+			if ctx.Err() != nil {
+				return
+			}
+
+			select {
+			case measures <- measure:
+			case <-ctx.Done():
+				return
+			}
+
+			if pinger.isLast(measure) || !pinger.sleep(ctx) {
+				return
+			}
 		}
 
 	}()
 	return measures
 }
+
+// pingAtFixedRate starts a request at each tick of the schedule without waiting for the completion of the previous
+// ones, up to MaxInFlight requests are in flight (each with its own client, so with its own connection), the ticks
+// happening while all of them are busy are skipped
+func (pinger *pingerImpl) pingAtFixedRate(ctx context.Context, measures chan<- *HTTPMeasure) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	maxInFlight := pinger.config.MaxInFlight
+	if pinger.config.LoadRate > 0 {
+		maxInFlight = pinger.config.LoadClients
+	}
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+	idle := make(chan WebClient, maxInFlight)
+	idle <- pinger.client
+	clients := 1
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	next := time.Now()
+	for sent := int64(0); sent < pinger.config.Count; {
+		var client WebClient
+		var err error
+		isNew := false
+		select {
+		case client = <-idle:
+		default:
+			if clients < maxInFlight {
+				if client, err = pinger.newClient(); err == nil {
+					clients++
+					isNew = true
+				}
+			}
+		}
+
+		switch {
+		case err != nil:
+			// the request couldn't be sent, the slot is a failed attempt rather than a skipped one
+			sent++
+			measure := &HTTPMeasure{IsFailure: true, FailureCause: err.Error(), IntendedStart: next, Start: time.Now()}
+			select {
+			case measures <- measure:
+			case <-ctx.Done():
+				return
+			}
+			if pinger.isLast(measure) {
+				cancel()
+				return
+			}
+		case client == nil:
+			select {
+			case measures <- &HTTPMeasure{Skipped: true, IntendedStart: next}:
+			case <-ctx.Done():
+				return
+			}
+		default:
+			sent++
+			wg.Add(1)
+			go func(client WebClient, intendedStart time.Time, isNew bool) {
+				defer wg.Done()
+				// the connection of a new client is established before its first measure, like the one of the first
+				// client, the delay is accounted in the schedule
+				if isNew {
+					pinger.warmUp(ctx, client)
+				}
+				start := time.Now()
+				measure := doMeasure(ctx, client, false)
+				measure.IntendedStart, measure.Start = intendedStart, start
+				idle <- client
+
+				if ctx.Err() != nil {
+					return
+				}
+				select {
+				case measures <- measure:
+				case <-ctx.Done():
+					return
+				}
+				if pinger.isLast(measure) {
+					cancel()
+				}
+			}(client, next, isNew)
+		}
+
+		next = next.Add(pinger.nextInterval())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// warmUp does a request with client which is not part of the measures, it returns false if there was no need for it
+// (there's no connection to be kept alive in TCP/TLS modes)
+func (pinger *pingerImpl) warmUp(ctx context.Context, client WebClient) bool {
+	if (pinger.config.DisableKeepAlive && !pinger.config.FollowRedirects) || !(isHTTPTarget(pinger.config.Target) || isGRPCTarget(pinger.config.Target)) {
+		return false
+	}
+	doMeasure(ctx, client, pinger.config.FollowRedirects)
+	return true
+}
+
+// isLast returns true if the run has to stop after this measure (until success or until failure)
+func (pinger *pingerImpl) isLast(measure *HTTPMeasure) bool {
+	return (pinger.config.UntilSuccess && !measure.IsFailure) || (pinger.config.UntilFailure && measure.IsFailure)
+}
+
+// scheduleInterval returns the mean time between the start of two requests, which depends on the rate in load mode
+func scheduleInterval(config *Config) time.Duration {
+	if config.LoadRate > 0 {
+		return time.Duration(float64(time.Second) / config.LoadRate)
+	}
+	return config.Interval
+}
+
+// nextInterval returns the time between the start of two requests, it's drawn from an exponential distribution with
+// Poisson intervals, or jittered if required, it's always positive so that the schedule moves forward
+func (pinger *pingerImpl) nextInterval() time.Duration {
+	interval := scheduleInterval(pinger.config)
+	if pinger.config.PoissonIntervals {
+		interval = time.Duration(pinger.random.ExpFloat64() * float64(interval))
+	} else if pinger.config.Jitter > 0 {
+		interval += time.Duration((2*pinger.random.Float64() - 1) * float64(pinger.config.Jitter))
+	}
+	if interval <= 0 {
+		return time.Nanosecond
+	}
+	return interval
+}
+
+// end returns the time at which the run has to stop (the earliest of the deadline and the end of the duration), zero
+// if the run is only bounded by the count
+func (pinger *pingerImpl) end(start time.Time) time.Time {
+	end := pinger.config.Deadline
+	if pinger.config.Duration > 0 && (end.IsZero() || start.Add(pinger.config.Duration).Before(end)) {
+		end = start.Add(pinger.config.Duration)
+	}
+	return end
+}
+
+// sleep waits for the (next) interval, it returns false if ctx is canceled (or the end of the run is reached) meanwhile
+func (pinger *pingerImpl) sleep(ctx context.Context) bool {
+	timer := time.NewTimer(pinger.nextInterval())
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
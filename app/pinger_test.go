@@ -17,10 +17,20 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fever.ch/http-ping/stats"
+	"sort"
 	"testing"
+	"time"
 )
 
-type webClientMock struct{}
+// webClientMock fails the first requests, each request lasts the latency
+type webClientMock struct {
+	failures int
+	requests int
+	latency  time.Duration
+}
 
 func TestPinger(t *testing.T) {
 	wanted := 123
@@ -37,8 +47,243 @@ func TestPinger(t *testing.T) {
 	}
 }
 
-func (webClientMock *webClientMock) DoMeasure(_ bool) *HTTPMeasure {
-	return &HTTPMeasure{}
+func TestPingerDuration(t *testing.T) {
+	pinger, _ := NewPinger(&Config{Count: 1000, Interval: 10 * time.Millisecond, Duration: 100 * time.Millisecond}, &RuntimeConfig{})
+	pinger.(*pingerImpl).client = &webClientMock{}
+
+	start := time.Now()
+	count := 0
+	for range pinger.Ping() {
+		count++
+	}
+	if count == 0 || count > 11 || time.Since(start) > time.Second {
+		t.Fatalf("run should have lasted 100 ms, it lasted %s with %d measures", time.Since(start), count)
+	}
+
+	// the request in flight at the end of the run is aborted
+	pinger, _ = NewPinger(&Config{Count: 1000, DisableKeepAlive: true, Duration: 100 * time.Millisecond}, &RuntimeConfig{})
+	pinger.(*pingerImpl).client = &webClientMock{latency: 10 * time.Second}
+
+	start = time.Now()
+	if _, ok := <-pinger.Ping(); ok || time.Since(start) > time.Second {
+		t.Fatalf("request in flight should have been aborted at the end of the run, it lasted %s", time.Since(start))
+	}
+
+	pinger, _ = NewPinger(&Config{Count: 1000, Deadline: time.Now().Add(-time.Second)}, &RuntimeConfig{})
+	pinger.(*pingerImpl).client = &webClientMock{}
+
+	if _, ok := <-pinger.Ping(); ok {
+		t.Fatal("no measure should be done after the deadline")
+	}
+}
+
+func TestPingerUntil(t *testing.T) {
+	// there's no warm-up request without keep-alive
+	pinger, _ := NewPinger(&Config{Count: 1000, DisableKeepAlive: true, UntilSuccess: true}, &RuntimeConfig{})
+	pinger.(*pingerImpl).client = &webClientMock{failures: 3}
+
+	count := 0
+	for range pinger.Ping() {
+		count++
+	}
+	if count != 4 {
+		t.Fatalf("pinger should have stopped after the first success, got %d measures", count)
+	}
+
+	pinger, _ = NewPinger(&Config{Count: 1000, DisableKeepAlive: true, UntilFailure: true}, &RuntimeConfig{})
+	pinger.(*pingerImpl).client = &webClientMock{failures: 1000}
+
+	count = 0
+	for range pinger.Ping() {
+		count++
+	}
+	if count != 1 {
+		t.Fatalf("pinger should have stopped after the first failure, got %d measures", count)
+	}
+}
+
+func TestPingContext(t *testing.T) {
+	pinger, _ := NewPinger(&Config{Count: 1000, Interval: 10 * time.Millisecond}, &RuntimeConfig{})
+	pinger.(*pingerImpl).client = &webClientMock{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	measures := pinger.(ContextPinger).PingContext(ctx)
+	<-measures
+	cancel()
+
+	// the producer may still deliver the measure in progress, then the channel has to be closed
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-measures:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("pinger should have stopped once the context was canceled")
+		}
+	}
+}
+
+// webClientWithoutContext implements WebClient only, like the clients written before ContextWebClient existed
+type webClientWithoutContext struct {
+	WebClient
+}
+
+func TestPingerWithoutContext(t *testing.T) {
+	pinger, _ := NewPinger(&Config{Count: 3, DisableKeepAlive: true}, &RuntimeConfig{})
+	pinger.(*pingerImpl).client = webClientWithoutContext{&webClientMock{}}
+
+	count := 0
+	for range pinger.Ping() {
+		count++
+	}
+	if count != 3 {
+		t.Fatalf("clients without DoMeasureContext should be supported, got %d measures", count)
+	}
+}
+
+func TestPingerFixedRate(t *testing.T) {
+	config := &Config{Count: 10, DisableKeepAlive: true, FixedRate: true, Interval: 10 * time.Millisecond}
+	pinger, _ := NewPinger(config, &RuntimeConfig{})
+	pinger.(*pingerImpl).client = &webClientMock{latency: 25 * time.Millisecond}
+
+	sent, skipped := 0, 0
+	start := time.Now()
+	var slots []time.Time
+	for measure := range pinger.Ping() {
+		if measure.Skipped {
+			skipped++
+		} else {
+			sent++
+			if measure.ScheduleDelay() > stats.Measure(9*time.Millisecond) {
+				t.Errorf("requests should be sent on schedule, one was %s late", time.Duration(measure.ScheduleDelay()))
+			}
+		}
+		slots = append(slots, measure.IntendedStart)
+	}
+
+	// the measures are received once complete, but their slots follow each other at the interval
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Before(slots[j]) })
+	for i := 1; i < len(slots); i++ {
+		if slots[i].Sub(slots[i-1]) != 10*time.Millisecond {
+			t.Fatalf("slots should be 10 ms apart, got %s", slots[i].Sub(slots[i-1]))
+		}
+	}
+	// each request takes the time of 3 slots: 2 out of 3 are skipped
+	if sent != 10 || skipped < 10 || time.Since(start) > time.Second {
+		t.Fatalf("slots should have been skipped, got %d measures and %d skipped slots", sent, skipped)
+	}
+
+	config.MaxInFlight = 4
+	pinger, _ = NewPinger(config, &RuntimeConfig{})
+	pinger.(*pingerImpl).client = &webClientMock{latency: 25 * time.Millisecond}
+	clients := 1
+	pinger.(*pingerImpl).newClient = func() (WebClient, error) {
+		clients++
+		return &webClientMock{latency: 25 * time.Millisecond}, nil
+	}
+
+	sent, skipped = 0, 0
+	for measure := range pinger.Ping() {
+		if measure.Skipped {
+			skipped++
+		} else {
+			sent++
+		}
+	}
+	if sent != 10 || skipped != 0 || clients > 4 {
+		t.Fatalf("requests should have overlapped, got %d measures, %d skipped slots and %d clients", sent, skipped, clients)
+	}
+}
+
+func TestPingerFixedRateNewClients(t *testing.T) {
+	// new clients are warmed up like the first one, so each of them does one request more than its measures
+	config := &Config{Target: "https://www.google.com", Count: 10, FixedRate: true, MaxInFlight: 4, Interval: 10 * time.Millisecond}
+	pinger, _ := NewPinger(config, &RuntimeConfig{})
+	mocks := []*webClientMock{{latency: 25 * time.Millisecond}}
+	pinger.(*pingerImpl).client = mocks[0]
+	pinger.(*pingerImpl).newClient = func() (WebClient, error) {
+		mocks = append(mocks, &webClientMock{latency: 25 * time.Millisecond})
+		return mocks[len(mocks)-1], nil
+	}
+
+	sent := 0
+	for measure := range pinger.Ping() {
+		if !measure.Skipped {
+			sent++
+		}
+	}
+	requests := 0
+	for _, mock := range mocks {
+		requests += mock.requests
+	}
+	if len(mocks) < 2 || requests != sent+len(mocks) {
+		t.Fatalf("each client should have been warmed up, got %d requests for %d measures and %d clients", requests, sent, len(mocks))
+	}
+
+	// clients which cannot be built are reported as failures
+	config = &Config{Count: 3, DisableKeepAlive: true, FixedRate: true, MaxInFlight: 4, Interval: 10 * time.Millisecond}
+	pinger, _ = NewPinger(config, &RuntimeConfig{})
+	pinger.(*pingerImpl).client = &webClientMock{latency: 100 * time.Millisecond}
+	pinger.(*pingerImpl).newClient = func() (WebClient, error) {
+		return nil, errors.New("no client")
+	}
+
+	failures := 0
+	for measure := range pinger.Ping() {
+		if measure.Skipped {
+			t.Fatal("slots without client should be failures rather than skipped")
+		}
+		if measure.IsFailure {
+			failures++
+			if measure.FailureCause != "no client" || measure.IntendedStart.IsZero() {
+				t.Errorf("failure to build a client should be reported: %s", measure.FailureCause)
+			}
+		}
+	}
+	if failures != 2 {
+		t.Fatalf("2 slots should have failed, got %d failures", failures)
+	}
+}
+
+func TestNextInterval(t *testing.T) {
+	pinger, _ := NewPinger(&Config{Interval: 100 * time.Millisecond, Jitter: 10 * time.Millisecond}, &RuntimeConfig{})
+
+	for i := 0; i < 100; i++ {
+		if interval := pinger.(*pingerImpl).nextInterval(); interval < 90*time.Millisecond || interval > 110*time.Millisecond {
+			t.Fatalf("jittered interval is out of range: %s", interval)
+		}
+	}
+
+	pinger, _ = NewPinger(&Config{Interval: 100 * time.Millisecond, PoissonIntervals: true}, &RuntimeConfig{})
+
+	var sum time.Duration
+	for i := 0; i < 10000; i++ {
+		sum += pinger.(*pingerImpl).nextInterval()
+	}
+	if mean := sum / 10000; mean < 90*time.Millisecond || mean > 110*time.Millisecond {
+		t.Fatalf("mean of the Poisson intervals should be the interval, got %s", mean)
+	}
+
+	pinger, _ = NewPinger(&Config{Interval: 0, PoissonIntervals: true}, &RuntimeConfig{})
+	if interval := pinger.(*pingerImpl).nextInterval(); interval <= 0 {
+		t.Fatalf("interval should always be positive, got %s", interval)
+	}
+}
+
+func (webClientMock *webClientMock) DoMeasureContext(ctx context.Context, _ bool) *HTTPMeasure {
+	select {
+	case <-time.After(webClientMock.latency):
+	case <-ctx.Done():
+		return &HTTPMeasure{IsFailure: true, FailureCause: ctx.Err().Error()}
+	}
+	webClientMock.requests++
+	return &HTTPMeasure{IsFailure: webClientMock.requests <= webClientMock.failures}
+}
+
+func (webClientMock *webClientMock) DoMeasure(followRedirect bool) *HTTPMeasure {
+	return webClientMock.DoMeasureContext(context.Background(), followRedirect)
 }
 
 func (webClientMock *webClientMock) URL() string {
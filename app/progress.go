@@ -0,0 +1,51 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"io"
+	"time"
+)
+
+// progressInterval is the minimal time between two reports of the progress of a download
+const progressInterval = 500 * time.Millisecond
+
+// progressReader reports periodically the count of bytes read
+type progressReader struct {
+	reader   io.Reader
+	callback func(bytes int64, elapsed time.Duration)
+
+	bytes      int64
+	start      time.Time
+	lastReport time.Time
+}
+
+func newProgressReader(reader io.Reader, callback func(bytes int64, elapsed time.Duration)) *progressReader {
+	now := time.Now()
+	return &progressReader{reader: reader, callback: callback, start: now, lastReport: now}
+}
+
+func (progress *progressReader) Read(p []byte) (int, error) {
+	n, err := progress.reader.Read(p)
+	progress.bytes += int64(n)
+
+	if now := time.Now(); now.Sub(progress.lastReport) >= progressInterval {
+		progress.lastReport = now
+		progress.callback(progress.bytes, now.Sub(progress.start))
+	}
+	return n, err
+}
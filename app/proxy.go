@@ -0,0 +1,141 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"golang.org/x/net/http/httpproxy"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type proxyTraceContextKey struct{}
+
+// proxyTrace records, for a single request, the proxy selected by the transport and the timing of the CONNECT tunnel
+// (or of the SOCKS negotiation)
+type proxyTrace struct {
+	proxyURL    *url.URL
+	tunnelTimer *timer
+
+	socksProxy string
+	socksTimer *timer
+}
+
+func withProxyTrace(ctx context.Context, trace *proxyTrace) context.Context {
+	return context.WithValue(ctx, proxyTraceContextKey{}, trace)
+}
+
+func contextProxyTrace(ctx context.Context) *proxyTrace {
+	trace, _ := ctx.Value(proxyTraceContextKey{}).(*proxyTrace)
+	return trace
+}
+
+// newProxyFunc returns the function selecting the proxy to be used for a request: the proxy defined in config (unless
+// the host matches the no-proxy list) or the proxy defined by the environment variables (HTTP_PROXY, ...), no proxy
+// is used when connecting through a Unix socket
+func newProxyFunc(config *Config) (func(req *http.Request) (*url.URL, error), error) {
+	var selectProxy func(req *http.Request) (*url.URL, error)
+
+	if config.SOCKS5 != "" || config.UnixSocket != "" {
+		// SOCKS proxies are handled while dialing, Unix sockets are dialed directly
+		selectProxy = func(*http.Request) (*url.URL, error) {
+			return nil, nil
+		}
+	} else if config.Proxy == "" {
+		// unlike http.ProxyFromEnvironment, the environment is read each time a client is built
+		environmentProxy := httpproxy.FromEnvironment().ProxyFunc()
+		selectProxy = func(req *http.Request) (*url.URL, error) {
+			return environmentProxy(req.URL)
+		}
+	} else {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, err
+		}
+
+		if config.ProxyUser != "" {
+			if credentials := strings.SplitN(config.ProxyUser, ":", 2); len(credentials) == 2 {
+				proxyURL.User = url.UserPassword(credentials[0], credentials[1])
+			} else {
+				proxyURL.User = url.User(credentials[0])
+			}
+		}
+
+		selectProxy = func(req *http.Request) (*url.URL, error) {
+			if matchNoProxy(req.URL.Hostname(), config.NoProxy) {
+				return nil, nil
+			}
+			return proxyURL, nil
+		}
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := selectProxy(req)
+		if trace := contextProxyTrace(req.Context()); trace != nil {
+			trace.proxyURL = proxyURL
+		}
+		return proxyURL, err
+	}, nil
+}
+
+// getProxyConnectHeader is called by the transport right before sending the CONNECT request to the proxy
+func getProxyConnectHeader(ctx context.Context, _ *url.URL, _ string) (http.Header, error) {
+	if trace := contextProxyTrace(ctx); trace != nil {
+		trace.tunnelTimer.start()
+	}
+	return nil, nil
+}
+
+// matchNoProxy returns true if host matches one of the entries of the comma-separated no-proxy list, entries can be
+// "*" (any host), a domain (which matches its subdomains as well), an IP address or a network in CIDR notation
+func matchNoProxy(host string, noProxy string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+
+		if entry == "*" {
+			return true
+		}
+
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		if entryIP := net.ParseIP(entry); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+
+		domain := strings.TrimPrefix(entry, ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
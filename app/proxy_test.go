@@ -0,0 +1,142 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestProxy returns an HTTP proxy, which supports CONNECT tunnels and checks the credentials (if defined)
+func newTestProxy(credentials string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if credentials != "" && r.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)) {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+
+		if r.Method != http.MethodConnect {
+			res, err := http.DefaultTransport.RoundTrip(r)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(res.StatusCode)
+			_, _ = io.Copy(w, res.Body)
+			_ = res.Body.Close()
+			return
+		}
+
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+		conn, buffer, _ := w.(http.Hijacker).Hijack()
+		go func() {
+			_, _ = io.Copy(upstream, buffer)
+			_ = upstream.Close()
+		}()
+		_, _ = io.Copy(conn, upstream)
+		_ = conn.Close()
+	}))
+}
+
+func TestProxyTunnel(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("Hello"))
+	}))
+	defer ts.Close()
+
+	proxy := newTestProxy("user:password")
+	defer proxy.Close()
+
+	webClient, err := NewWebClient(&Config{Target: ts.URL, NoCheckCertificate: true, Proxy: proxy.URL, ProxyUser: "user:password"}, &RuntimeConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	measure := webClient.DoMeasure(false)
+	if measure.IsFailure || measure.StatusCode != 200 {
+		t.Fatalf("Request through the proxy should have succeed: %s", measure.FailureCause)
+	}
+
+	if measure.Proxy != proxy.Listener.Addr().String() || !measure.ProxyConnection.IsValid() || !measure.ProxyTunnel.IsValid() ||
+		!measure.TLSDuration.IsValid() || measure.ProxyTLSDuration.IsValid() {
+		t.Errorf("Proxy phases haven't been measured correctly")
+	}
+
+	webClient, _ = NewWebClient(&Config{Target: ts.URL, NoCheckCertificate: true, Proxy: proxy.URL}, &RuntimeConfig{})
+
+	if measure = webClient.DoMeasure(false); !measure.IsFailure {
+		t.Errorf("Request through the proxy should have failed (authentication required)")
+	}
+}
+
+func TestProxyPlainHTTP(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("Hello"))
+	}))
+	defer ts.Close()
+
+	proxy := newTestProxy("")
+	defer proxy.Close()
+
+	webClient, _ := NewWebClient(&Config{Target: ts.URL, Proxy: proxy.URL}, &RuntimeConfig{})
+
+	measure := webClient.DoMeasure(false)
+	if measure.IsFailure || measure.Proxy == "" || measure.RemoteAddr != proxy.Listener.Addr().String() || measure.ProxyTunnel.IsValid() {
+		t.Errorf("Request should have been sent to the proxy: %s", measure.FailureCause)
+	}
+
+	webClient, _ = NewWebClient(&Config{Target: ts.URL, Proxy: proxy.URL, NoProxy: "127.0.0.0/8"}, &RuntimeConfig{})
+
+	measure = webClient.DoMeasure(false)
+	if measure.IsFailure || measure.Proxy != "" || measure.RemoteAddr != ts.Listener.Addr().String() {
+		t.Errorf("Request should have bypassed the proxy: %s", measure.FailureCause)
+	}
+}
+
+func TestMatchNoProxy(t *testing.T) {
+	cases := []struct {
+		host    string
+		noProxy string
+		want    bool
+	}{
+		{"www.example.com", "example.com", true},
+		{"www.example.com", ".example.com", true},
+		{"example.com", ".example.com", true},
+		{"www.example.org", "localhost, example.com", false},
+		{"badexample.com", "example.com", false},
+		{"10.1.2.3", "10.0.0.0/8", true},
+		{"192.168.1.1", "10.0.0.0/8,192.168.1.1", true},
+		{"www.example.com", "*", true},
+		{"www.example.com", "", false},
+	}
+
+	for _, c := range cases {
+		if got := matchNoProxy(c.host, c.noProxy); got != c.want {
+			t.Errorf("matchNoProxy(%s, %s) = %t, want %t", c.host, c.noProxy, got, c.want)
+		}
+	}
+}
@@ -17,6 +17,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"github.com/domainr/dnsr"
 	"github.com/miekg/dns"
@@ -49,9 +50,7 @@ func (resolver *resolver) resolveConn(addr string) (string, error) {
 	}
 }
 
-func resolveWithSpecificServerQtype(qtype uint16, server string, host string) ([]*net.IP, error) {
-	var ips []*net.IP
-
+func newDNSQuery(qtype uint16, host string, clientSubnet string) (*dns.Msg, error) {
 	msg := new(dns.Msg)
 	msg.Id = dns.Id()
 	msg.RecursionDesired = true
@@ -59,7 +58,50 @@ func resolveWithSpecificServerQtype(qtype uint16, server string, host string) ([
 
 	msg.Question = append(msg.Question, dns.Question{Name: host, Qtype: qtype, Qclass: dns.ClassINET})
 
-	c := new(dns.Client)
+	if clientSubnet != "" {
+		_, subnet, err := net.ParseCIDR(clientSubnet)
+		if err != nil {
+			return nil, err
+		}
+
+		ones, _ := subnet.Mask.Size()
+
+		// EDNS Client Subnet (RFC 7871), family 1 is IPv4 and family 2 is IPv6
+		ecs := &dns.EDNS0_SUBNET{
+			Code:          dns.EDNS0SUBNET,
+			SourceNetmask: uint8(ones),
+			SourceScope:   0,
+		}
+		if ip4 := subnet.IP.To4(); ip4 != nil {
+			ecs.Family = 1
+			ecs.Address = ip4
+		} else {
+			ecs.Family = 2
+			ecs.Address = subnet.IP
+		}
+
+		msg.SetEdns0(dns.DefaultMsgSize, false)
+		opt := msg.IsEdns0()
+		opt.Option = append(opt.Option, ecs)
+	}
+
+	return msg, nil
+}
+
+func (resolver *resolver) resolveWithSpecificServerQtype(qtype uint16, server string, host string) ([]*net.IP, error) {
+	var ips []*net.IP
+
+	msg, err := newDNSQuery(qtype, host, resolver.config.DNSClientSubnet)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer, err := newDialer(resolver.config, "udp")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &dns.Client{Dialer: dialer}
 
 	in, _, err := c.Exchange(msg, fmt.Sprintf("%s:53", server))
 
@@ -77,7 +119,7 @@ func resolveWithSpecificServerQtype(qtype uint16, server string, host string) ([
 	return ips, nil
 }
 
-func resolveWithSpecificServer(network, server string, host string) ([]*net.IP, error) {
+func (resolver *resolver) resolveWithSpecificServer(network, server string, host string) ([]*net.IP, error) {
 
 	type resolveAnswer struct {
 		ip    []*net.IP
@@ -85,15 +127,15 @@ func resolveWithSpecificServer(network, server string, host string) ([]*net.IP,
 		qtype uint16
 	}
 	if network == "ip4" {
-		return resolveWithSpecificServerQtype(dns.TypeA, server, host)
+		return resolver.resolveWithSpecificServerQtype(dns.TypeA, server, host)
 	} else if network == "ip6" {
-		return resolveWithSpecificServerQtype(dns.TypeAAAA, server, host)
+		return resolver.resolveWithSpecificServerQtype(dns.TypeAAAA, server, host)
 	} else {
 		var ips []*net.IP
 
 		answersChan := make(chan *resolveAnswer)
 		ret := func(qtype uint16) {
-			out, err := resolveWithSpecificServerQtype(qtype, server, host)
+			out, err := resolver.resolveWithSpecificServerQtype(qtype, server, host)
 
 			answersChan <- &resolveAnswer{out, err, qtype}
 
@@ -156,7 +198,7 @@ func (resolver *resolver) actualResolve(addr string) (*net.IPAddr, error) {
 		}
 		return &net.IPAddr{IP: ip}, nil
 	} else if resolver.config.DNSServer != "" {
-		ip, err := resolveWithSpecificServer(resolver.config.IPProtocol, resolver.config.DNSServer, fmt.Sprintf("%s.", addr))
+		ip, err := resolver.resolveWithSpecificServer(resolver.config.IPProtocol, resolver.config.DNSServer, fmt.Sprintf("%s.", addr))
 		if err != nil {
 			return nil, err
 		}
@@ -166,11 +208,49 @@ func (resolver *resolver) actualResolve(addr string) (*net.IPAddr, error) {
 		}
 
 		return &net.IPAddr{IP: *ip[0]}, nil
+	} else if isDialerBound(resolver.config) {
+		return resolver.resolveWithBoundDialer(addr)
 	} else {
 		return net.ResolveIPAddr(resolver.config.IPProtocol, addr)
 	}
 }
 
+// resolveWithBoundDialer resolves addr with the system's DNS configuration, queries are sent from the source address
+// and/or the network interface defined in the config
+func (resolver *resolver) resolveWithBoundDialer(addr string) (*net.IPAddr, error) {
+	netResolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialer, err := newDialer(resolver.config, network)
+			if err != nil {
+				return nil, err
+			}
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+
+	network := resolver.config.IPProtocol
+	if network == "" {
+		network = "ip"
+	}
+
+	ips, err := netResolver.LookupIP(context.Background(), network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// same policy as net.ResolveIPAddr: IPv4 addresses are preferred
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return &net.IPAddr{IP: ip}, nil
+		}
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+	}
+	return &net.IPAddr{IP: ips[0]}, nil
+}
+
 func (*resolver) fullResolveFromRoot(network, host string) (*string, error) {
 	var qtypes []string
 
@@ -0,0 +1,61 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"github.com/miekg/dns"
+	"net"
+	"testing"
+)
+
+func TestDNSQueryWithClientSubnet(t *testing.T) {
+	msg, err := newDNSQuery(dns.TypeA, "www.google.com.", "203.0.113.0/24")
+	if err != nil {
+		t.Fatalf("query should have been built: %s", err)
+	}
+
+	opt := msg.IsEdns0()
+	if opt == nil || len(opt.Option) != 1 {
+		t.Fatal("EDNS client subnet option missing")
+	}
+
+	ecs, ok := opt.Option[0].(*dns.EDNS0_SUBNET)
+	if !ok || ecs.Family != 1 || ecs.SourceNetmask != 24 || !ecs.Address.Equal(net.ParseIP("203.0.113.0")) {
+		t.Fatalf("unexpected EDNS client subnet option: %v", opt.Option[0])
+	}
+
+	msg, err = newDNSQuery(dns.TypeAAAA, "www.google.com.", "2001:db8::/56")
+	if err != nil {
+		t.Fatalf("query should have been built: %s", err)
+	}
+
+	ecs, ok = msg.IsEdns0().Option[0].(*dns.EDNS0_SUBNET)
+	if !ok || ecs.Family != 2 || ecs.SourceNetmask != 56 {
+		t.Fatalf("unexpected EDNS client subnet option: %v", ecs)
+	}
+}
+
+func TestDNSQueryWithoutClientSubnet(t *testing.T) {
+	msg, err := newDNSQuery(dns.TypeA, "www.google.com.", "")
+	if err != nil || msg.IsEdns0() != nil {
+		t.Fatal("no EDNS option should be present without client subnet")
+	}
+
+	if _, err := newDNSQuery(dns.TypeA, "www.google.com.", "203.0.113.0"); err == nil {
+		t.Fatal("invalid client subnet should be rejected")
+	}
+}
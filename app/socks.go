@@ -0,0 +1,109 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"errors"
+	"fever.ch/http-ping/net/sockettrace"
+	"golang.org/x/net/proxy"
+	"net"
+	"net/url"
+)
+
+// socksForwardDialer establishes the connections with the SOCKS proxy, these connections are traced like the direct
+// connections to the targets
+type socksForwardDialer struct {
+	webClient *webClientImpl
+	dialer    *net.Dialer
+}
+
+func (forward *socksForwardDialer) Dial(network, addr string) (net.Conn, error) {
+	return forward.DialContext(context.Background(), network, addr)
+}
+
+func (forward *socksForwardDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	ipaddr, err := forward.webClient.proxyResolver().resolveConn(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sockettrace.NewSocketTrace(ctx, forward.dialer, network, ipaddr)
+	if err != nil {
+		return nil, err
+	}
+	forward.webClient.registerConn(conn)
+
+	// the SOCKS negotiation starts as soon as the connection with the proxy is established
+	if trace := contextProxyTrace(ctx); trace != nil {
+		trace.socksTimer.start()
+	}
+	return conn, nil
+}
+
+// newSOCKSDialer builds a dialer for the SOCKS5 proxy defined in config, in the form [user:password@]host:port
+func newSOCKSDialer(config *Config, forward *socksForwardDialer) (proxy.ContextDialer, string, error) {
+	proxyURL, err := url.Parse("socks5://" + config.SOCKS5)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var auth *proxy.Auth
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+	}
+
+	dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, forward)
+	if err != nil {
+		return nil, "", err
+	}
+
+	contextDialer, ok := dialer.(proxy.ContextDialer)
+	if !ok {
+		return nil, "", errors.New("SOCKS5 dialer doesn't support contexts")
+	}
+	return contextDialer, proxyURL.Host, nil
+}
+
+// dialSOCKS connects to the target through the SOCKS proxy, the target is resolved locally unless remote DNS
+// resolution is enabled (the hostname is then sent to the proxy)
+func (webClient *webClientImpl) dialSOCKS(ctx context.Context, network string) (net.Conn, error) {
+	target := webClient.connTarget
+
+	if webClient.config.ConnTarget == "" && !webClient.config.SOCKS5RemoteDNS {
+		startDNSHook(ctx)
+		resolvedTarget, err := webClient.resolver.resolveConn(target)
+		if err != nil {
+			return nil, err
+		}
+		stopDNSHook(ctx)
+		target = resolvedTarget
+	}
+
+	trace := contextProxyTrace(ctx)
+	if trace != nil {
+		trace.socksProxy = webClient.socksProxy
+	}
+
+	conn, err := webClient.socksDialer.DialContext(ctx, network, target)
+
+	if trace != nil {
+		trace.socksTimer.stop()
+	}
+	return conn, err
+}
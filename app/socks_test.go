@@ -0,0 +1,184 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// startTestSOCKS5 runs a minimal SOCKS5 proxy (CONNECT only), the requested destinations are sent to destinations
+func startTestSOCKS5(t *testing.T, user, password string, destinations chan<- string) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handle := func(conn net.Conn) error {
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+			return err
+		}
+
+		if user == "" {
+			_, _ = conn.Write([]byte{5, 0})
+		} else {
+			_, _ = conn.Write([]byte{5, 2})
+
+			// username/password authentication (RFC 1929)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return err
+			}
+			u := make([]byte, header[1])
+			_, _ = io.ReadFull(conn, u)
+			_, _ = io.ReadFull(conn, header[:1])
+			p := make([]byte, header[0])
+			_, _ = io.ReadFull(conn, p)
+			if string(u) != user || string(p) != password {
+				_, _ = conn.Write([]byte{1, 1})
+				return fmt.Errorf("authentication failed")
+			}
+			_, _ = conn.Write([]byte{1, 0})
+		}
+
+		request := make([]byte, 4)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return err
+		}
+
+		var host string
+		switch request[3] {
+		case 1:
+			ip := make([]byte, 4)
+			_, _ = io.ReadFull(conn, ip)
+			host = net.IP(ip).String()
+		case 3:
+			_, _ = io.ReadFull(conn, header[:1])
+			name := make([]byte, header[0])
+			_, _ = io.ReadFull(conn, name)
+			host = string(name)
+		default:
+			return fmt.Errorf("unsupported address type")
+		}
+		_, _ = io.ReadFull(conn, header)
+		destination := net.JoinHostPort(host, fmt.Sprintf("%d", binary.BigEndian.Uint16(header)))
+		destinations <- destination
+
+		upstream, err := net.Dial("tcp", strings.Replace(destination, "localhost", "127.0.0.1", 1))
+		if err != nil {
+			_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+			return err
+		}
+		_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+		go func() {
+			_, _ = io.Copy(upstream, conn)
+			_ = upstream.Close()
+		}()
+		_, err = io.Copy(conn, upstream)
+		return err
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = handle(conn)
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), func() {
+		_ = listener.Close()
+	}
+}
+
+func TestSOCKS5(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("Hello"))
+	}))
+	defer ts.Close()
+
+	destinations := make(chan string, 10)
+	socksAddr, stop := startTestSOCKS5(t, "user", "password", destinations)
+	defer stop()
+
+	webClient, err := NewWebClient(&Config{Target: ts.URL, NoCheckCertificate: true, SOCKS5: "user:password@" + socksAddr}, &RuntimeConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	measure := webClient.DoMeasure(false)
+	if measure.IsFailure || measure.StatusCode != 200 {
+		t.Fatalf("Request through the SOCKS5 proxy should have succeed: %s", measure.FailureCause)
+	}
+
+	if <-destinations != ts.Listener.Addr().String() {
+		t.Errorf("SOCKS5 proxy should have been asked to connect to the target")
+	}
+
+	if measure.Proxy != socksAddr || measure.RemoteAddr != socksAddr || !measure.SOCKSNegotiation.IsValid() ||
+		!measure.ProxyConnection.IsValid() || !measure.DNSResolution.IsValid() || measure.InBytes == 0 || measure.OutBytes == 0 {
+		t.Errorf("SOCKS5 phases haven't been measured correctly")
+	}
+
+	webClient, _ = NewWebClient(&Config{Target: ts.URL, NoCheckCertificate: true, SOCKS5: "user:wrong@" + socksAddr}, &RuntimeConfig{})
+
+	if measure = webClient.DoMeasure(false); !measure.IsFailure {
+		t.Errorf("Request through the SOCKS5 proxy should have failed (wrong password)")
+	}
+}
+
+func TestSOCKS5RemoteDNS(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("Hello"))
+	}))
+	defer ts.Close()
+
+	destinations := make(chan string, 10)
+	socksAddr, stop := startTestSOCKS5(t, "", "", destinations)
+	defer stop()
+
+	target := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+
+	webClient, _ := NewWebClient(&Config{Target: target, SOCKS5: socksAddr, SOCKS5RemoteDNS: true}, &RuntimeConfig{})
+
+	measure := webClient.DoMeasure(false)
+	if measure.IsFailure || measure.DNSResolution.IsValid() {
+		t.Fatalf("Request through the SOCKS5 proxy should have succeed, without local resolution: %s", measure.FailureCause)
+	}
+
+	if destination := <-destinations; !strings.HasPrefix(destination, "localhost:") {
+		t.Errorf("SOCKS5 proxy should have been asked to resolve the target, got %s", destination)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// startTLSUpgrades contains, for each supported protocol, the dialogue asking the server to switch to TLS
+var startTLSUpgrades = map[string]func(conn net.Conn, reader *bufio.Reader) error{
+	"smtp":     startTLSSMTP,
+	"imap":     startTLSIMAP,
+	"pop3":     startTLSPOP3,
+	"postgres": startTLSPostgres,
+}
+
+// StartTLSProtocols returns the list of protocols which can be upgraded to TLS with STARTTLS
+func StartTLSProtocols() []string {
+	var protocols []string
+	for protocol := range startTLSUpgrades {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	return protocols
+}
+
+// startTLS runs the protocol-specific dialogue which precedes the TLS handshake on conn
+func startTLS(ctx context.Context, conn net.Conn, protocol string) error {
+	upgrade, ok := startTLSUpgrades[protocol]
+	if !ok {
+		return fmt.Errorf("STARTTLS is not supported for protocol %s", protocol)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer func() {
+			_ = conn.SetDeadline(time.Time{})
+		}()
+	}
+
+	reader := bufio.NewReader(conn)
+	if err := upgrade(conn, reader); err != nil {
+		return fmt.Errorf("STARTTLS (%s): %s", protocol, err)
+	}
+
+	// nothing is expected from the server before the TLS handshake
+	if reader.Buffered() > 0 {
+		return fmt.Errorf("STARTTLS (%s): unexpected data received before TLS handshake", protocol)
+	}
+	return nil
+}
+
+func writeLine(conn net.Conn, line string) error {
+	_, err := io.WriteString(conn, line+"\r\n")
+	return err
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readSMTPReply reads a (possibly multi-line) SMTP reply and checks its code
+func readSMTPReply(reader *bufio.Reader, code string) error {
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, code) {
+			return fmt.Errorf("unexpected reply: %s", line)
+		}
+		if len(line) == len(code) || line[len(code)] != '-' {
+			return nil
+		}
+	}
+}
+
+func startTLSSMTP(conn net.Conn, reader *bufio.Reader) error {
+	if err := readSMTPReply(reader, "220"); err != nil {
+		return err
+	}
+	if err := writeLine(conn, "EHLO http-ping"); err != nil {
+		return err
+	}
+	if err := readSMTPReply(reader, "250"); err != nil {
+		return err
+	}
+	if err := writeLine(conn, "STARTTLS"); err != nil {
+		return err
+	}
+	return readSMTPReply(reader, "220")
+}
+
+func startTLSIMAP(conn net.Conn, reader *bufio.Reader) error {
+	if line, err := readLine(reader); err != nil {
+		return err
+	} else if !strings.HasPrefix(line, "* OK") {
+		return fmt.Errorf("unexpected greeting: %s", line)
+	}
+
+	if err := writeLine(conn, "a001 STARTTLS"); err != nil {
+		return err
+	}
+
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(line, "a001 OK") {
+			return nil
+		} else if strings.HasPrefix(line, "a001 ") {
+			return fmt.Errorf("unexpected reply: %s", line)
+		}
+	}
+}
+
+func startTLSPOP3(conn net.Conn, reader *bufio.Reader) error {
+	if line, err := readLine(reader); err != nil {
+		return err
+	} else if !strings.HasPrefix(line, "+OK") {
+		return fmt.Errorf("unexpected greeting: %s", line)
+	}
+
+	if err := writeLine(conn, "STLS"); err != nil {
+		return err
+	}
+
+	if line, err := readLine(reader); err != nil {
+		return err
+	} else if !strings.HasPrefix(line, "+OK") {
+		return fmt.Errorf("unexpected reply: %s", line)
+	}
+	return nil
+}
+
+// postgresSSLRequestCode is the code of the SSLRequest message of the PostgreSQL frontend/backend protocol
+const postgresSSLRequestCode = 80877103
+
+func startTLSPostgres(conn net.Conn, reader *bufio.Reader) error {
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], postgresSSLRequestCode)
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	answer, err := reader.ReadByte()
+	if err != nil {
+		return err
+	}
+	if answer != 'S' {
+		return errors.New("server refused SSL")
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTLSStandIn runs a server speaking just enough of a protocol to upgrade its connections to TLS
+func startTLSStandIn(t *testing.T, dialogue func(conn net.Conn, reader *bufio.Reader) bool) (string, func()) {
+	// borrow the certificate of httptest
+	ts := httptest.NewUnstartedServer(nil)
+	ts.StartTLS()
+	tlsConfig := ts.TLS.Clone()
+	ts.Close()
+
+	// like PostgreSQL, the server aborts handshakes offering other protocols with ALPN
+	tlsConfig.NextProtos = []string{"postgresql"}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() {
+					_ = conn.Close()
+				}()
+				if dialogue(conn, bufio.NewReader(conn)) {
+					_ = tls.Server(conn, tlsConfig).Handshake()
+				}
+			}()
+		}
+	}()
+
+	return fmt.Sprintf("tls://%s", listener.Addr().String()), func() {
+		_ = listener.Close()
+	}
+}
+
+func expectLine(reader *bufio.Reader, prefix string) bool {
+	line, err := reader.ReadString('\n')
+	return err == nil && strings.HasPrefix(line, prefix)
+}
+
+func testStartTLS(t *testing.T, protocol string, dialogue func(conn net.Conn, reader *bufio.Reader) bool) {
+	testStartTLSWithALPN(t, protocol, nil, dialogue)
+}
+
+func testStartTLSWithALPN(t *testing.T, protocol string, alpn []string, dialogue func(conn net.Conn, reader *bufio.Reader) bool) {
+	target, stop := startTLSStandIn(t, dialogue)
+	defer stop()
+
+	tlsClient, err := NewTLSClient(&Config{Target: target, Wait: time.Second, NoCheckCertificate: true, StartTLS: protocol, ALPN: alpn}, &RuntimeConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	measure := tlsClient.DoMeasure(false)
+	if measure.IsFailure || !measure.StartTLSDuration.IsValid() || !measure.TLSDuration.IsValid() || len(measure.TLSCertificates) == 0 {
+		t.Errorf("%s: TLS session should have been established with STARTTLS: %s", protocol, measure.FailureCause)
+	}
+
+	if len(alpn) == 0 && measure.TLSALPN != "" {
+		t.Errorf("%s: no protocol should have been offered with ALPN, got %s", protocol, measure.TLSALPN)
+	} else if len(alpn) > 0 && measure.TLSALPN != alpn[0] {
+		t.Errorf("%s: %s should have been negotiated with ALPN, got %s", protocol, alpn[0], measure.TLSALPN)
+	}
+}
+
+func TestStartTLSSMTP(t *testing.T) {
+	testStartTLS(t, "smtp", func(conn net.Conn, reader *bufio.Reader) bool {
+		_, _ = io.WriteString(conn, "220-smtp.example.com ESMTP\r\n220 ready\r\n")
+		if !expectLine(reader, "EHLO ") {
+			return false
+		}
+		_, _ = io.WriteString(conn, "250-smtp.example.com\r\n250-PIPELINING\r\n250 STARTTLS\r\n")
+		if !expectLine(reader, "STARTTLS") {
+			return false
+		}
+		_, _ = io.WriteString(conn, "220 2.0.0 Ready to start TLS\r\n")
+		return true
+	})
+}
+
+func TestStartTLSIMAP(t *testing.T) {
+	testStartTLS(t, "imap", func(conn net.Conn, reader *bufio.Reader) bool {
+		_, _ = io.WriteString(conn, "* OK [CAPABILITY IMAP4rev1 STARTTLS] ready\r\n")
+		if !expectLine(reader, "a001 STARTTLS") {
+			return false
+		}
+		_, _ = io.WriteString(conn, "a001 OK Begin TLS negotiation now\r\n")
+		return true
+	})
+}
+
+func TestStartTLSPOP3(t *testing.T) {
+	testStartTLS(t, "pop3", func(conn net.Conn, reader *bufio.Reader) bool {
+		_, _ = io.WriteString(conn, "+OK POP3 ready\r\n")
+		if !expectLine(reader, "STLS") {
+			return false
+		}
+		_, _ = io.WriteString(conn, "+OK Begin TLS negotiation\r\n")
+		return true
+	})
+}
+
+func TestStartTLSPostgres(t *testing.T) {
+	dialogue := func(conn net.Conn, reader *bufio.Reader) bool {
+		request := make([]byte, 8)
+		if _, err := io.ReadFull(reader, request); err != nil || fmt.Sprintf("%x", request) != "0000000804d2162f" {
+			return false
+		}
+		_, _ = conn.Write([]byte{'S'})
+		return true
+	}
+
+	testStartTLS(t, "postgres", dialogue)
+	testStartTLSWithALPN(t, "postgres", []string{"postgresql"}, dialogue)
+}
+
+func TestStartTLSRefused(t *testing.T) {
+	target, stop := startTLSStandIn(t, func(conn net.Conn, reader *bufio.Reader) bool {
+		_, _ = io.WriteString(conn, "+OK POP3 ready\r\n")
+		if expectLine(reader, "STLS") {
+			_, _ = io.WriteString(conn, "-ERR TLS not available\r\n")
+		}
+		return false
+	})
+	defer stop()
+
+	tlsClient, _ := NewTLSClient(&Config{Target: target, Wait: time.Second, NoCheckCertificate: true, StartTLS: "pop3"}, &RuntimeConfig{})
+
+	if measure := tlsClient.DoMeasure(false); !measure.IsFailure || !strings.Contains(measure.FailureCause, "STARTTLS") {
+		t.Errorf("STARTTLS should have failed")
+	}
+}
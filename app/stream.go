@@ -0,0 +1,193 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fever.ch/http-ping/stats"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// StreamStats are the timings of a streamed response (i.e. Server-Sent Events), events are SSE events unless the first
+// event is defined by a count of lines (events are then lines) or a count of bytes (events are then received chunks)
+type StreamStats struct {
+	TimeToFirstByte  stats.Measure
+	TimeToFirstEvent stats.Measure
+	Events           int64
+	GapAverage       stats.Measure
+	GapMax           stats.Measure
+	Truncated        bool
+}
+
+// streamReader consumes a streamed response and measures the arrival of its events
+type streamReader struct {
+	config *Config
+
+	start     time.Time
+	firstByte time.Time
+	truncated bool
+
+	bytes       int64
+	lines       int64
+	lineLength  int
+	commentLine bool
+	pendingCR   bool
+	pendingData bool
+
+	events     int64
+	firstEvent time.Time
+	lastEvent  time.Time
+	gapSum     time.Duration
+	gapMax     time.Duration
+}
+
+func newStreamReader(config *Config) *streamReader {
+	return &streamReader{config: config}
+}
+
+// requestStarted and gotFirstByte are called from the trace of the request
+func (stream *streamReader) requestStarted() {
+	stream.start = time.Now()
+}
+
+func (stream *streamReader) gotFirstByte() {
+	stream.firstByte = time.Now()
+}
+
+// consume reads body until its end, or until the maximal duration of streams is reached (the body is then closed)
+func (stream *streamReader) consume(body io.ReadCloser) (int64, error) {
+	var truncated int32
+	if stream.config.StreamDuration > 0 {
+		timer := time.AfterFunc(stream.config.StreamDuration, func() {
+			atomic.StoreInt32(&truncated, 1)
+			_ = body.Close()
+		})
+		defer timer.Stop()
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			stream.received(buf[:n], time.Now())
+		}
+
+		if err == io.EOF {
+			return stream.bytes, nil
+		} else if err != nil {
+			if atomic.LoadInt32(&truncated) == 1 {
+				stream.truncated = true
+				return stream.bytes, nil
+			}
+			return stream.bytes, err
+		}
+	}
+}
+
+func (stream *streamReader) received(chunk []byte, ts time.Time) {
+	stream.bytes += int64(len(chunk))
+
+	if stream.config.StreamFirstBytes > 0 {
+		if stream.bytes >= stream.config.StreamFirstBytes {
+			stream.event(ts)
+		}
+		return
+	}
+
+	for _, b := range chunk {
+		if b == '\n' && stream.pendingCR {
+			// CRLF line ending
+			stream.pendingCR = false
+			continue
+		}
+		stream.pendingCR = b == '\r'
+
+		if b == '\r' || b == '\n' {
+			stream.endOfLine(ts)
+		} else {
+			if stream.lineLength == 0 {
+				stream.commentLine = b == ':'
+			}
+			stream.lineLength++
+		}
+	}
+}
+
+func (stream *streamReader) endOfLine(ts time.Time) {
+	stream.lines++
+
+	if stream.config.StreamFirstLines > 0 {
+		if stream.lines >= stream.config.StreamFirstLines {
+			stream.event(ts)
+		}
+	} else if stream.lineLength == 0 {
+		// an empty line dispatches the SSE event, if some fields have been received (comments don't count)
+		if stream.pendingData {
+			stream.event(ts)
+		}
+		stream.pendingData = false
+	} else if !stream.commentLine {
+		stream.pendingData = true
+	}
+
+	stream.lineLength = 0
+}
+
+func (stream *streamReader) event(ts time.Time) {
+	if stream.events == 0 {
+		stream.firstEvent = ts
+	} else {
+		gap := ts.Sub(stream.lastEvent)
+		stream.gapSum += gap
+		if gap > stream.gapMax {
+			stream.gapMax = gap
+		}
+	}
+	stream.lastEvent = ts
+	stream.events++
+}
+
+// complete fills the measure with the statistics of the stream, a stream without any event is a failure
+func (stream *streamReader) complete(measure *HTTPMeasure) {
+	streamStats := &StreamStats{
+		TimeToFirstByte:  stats.MeasureNotValid,
+		TimeToFirstEvent: stats.MeasureNotValid,
+		Events:           stream.events,
+		GapAverage:       stats.MeasureNotValid,
+		GapMax:           stats.MeasureNotValid,
+		Truncated:        stream.truncated,
+	}
+
+	if !stream.firstByte.IsZero() {
+		streamStats.TimeToFirstByte = stats.Measure(stream.firstByte.Sub(stream.start))
+	}
+	if stream.events > 0 {
+		streamStats.TimeToFirstEvent = stats.Measure(stream.firstEvent.Sub(stream.start))
+	}
+	if stream.events > 1 {
+		streamStats.GapAverage = stats.Measure(stream.gapSum / time.Duration(stream.events-1))
+		streamStats.GapMax = stats.Measure(stream.gapMax)
+	}
+
+	measure.Stream = streamStats
+
+	if stream.events == 0 && !measure.IsFailure {
+		measure.IsFailure = true
+		measure.FailureCause = "No event received from the stream"
+	}
+}
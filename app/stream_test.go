@@ -0,0 +1,121 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fever.ch/http-ping/stats"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStreamReaderEvents(t *testing.T) {
+	stream := newStreamReader(&Config{})
+	now := time.Now()
+
+	// a comment, an event split over two chunks, an event with CRLF line endings and an unterminated event
+	stream.received([]byte(": keep-alive\n\ndata: first\n"), now)
+	stream.received([]byte("\nevent: second\r\ndata: x\r\n\r\ndata: third"), now.Add(10*time.Millisecond))
+
+	if stream.events != 2 || !stream.firstEvent.Equal(now.Add(10*time.Millisecond)) {
+		t.Errorf("2 SSE events should have been received, got %d", stream.events)
+	}
+
+	stream = newStreamReader(&Config{StreamFirstLines: 3})
+	stream.received([]byte("a\nb\n"), now)
+	stream.received([]byte("c\nd\n"), now)
+	if stream.events != 2 || stream.firstEvent != now {
+		t.Errorf("Lines after the third one should have been considered as events, got %d", stream.events)
+	}
+
+	stream = newStreamReader(&Config{StreamFirstBytes: 5})
+	stream.received([]byte("abc"), now)
+	stream.received([]byte("def"), now)
+	stream.received([]byte("g"), now)
+	if stream.events != 2 {
+		t.Errorf("Chunks should have been considered as events once 5 bytes are received, got %d", stream.events)
+	}
+}
+
+func TestStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+
+		events := 3
+		if r.URL.Path == "/endless" {
+			events = 1000
+		} else if r.URL.Path == "/silent" {
+			events = 0
+		}
+
+		_, _ = fmt.Fprintf(w, ": connected\n\n")
+		flusher.Flush()
+
+		for i := 0; i < events; i++ {
+			time.Sleep(20 * time.Millisecond)
+			if _, err := fmt.Fprintf(w, "id: %d\ndata: event %d\n\n", i, i); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}))
+	defer ts.Close()
+
+	webClient, _ := NewWebClient(&Config{Target: ts.URL + "/events", Method: "GET", Stream: true, StreamDuration: 5 * time.Second}, &RuntimeConfig{})
+
+	measure := webClient.DoMeasure(false)
+	if measure.IsFailure || measure.Stream == nil {
+		t.Fatalf("Stream should have been read: %s", measure.FailureCause)
+	}
+
+	stream := measure.Stream
+	minGap := stats.Measure(20 * time.Millisecond)
+	if stream.Events != 3 || stream.Truncated || stream.TimeToFirstEvent < stream.TimeToFirstByte ||
+		stream.TimeToFirstEvent < minGap || stream.GapAverage < minGap || stream.GapMax < stream.GapAverage {
+		t.Errorf("Stream timings aren't consistent: %+v", stream)
+	}
+
+	webClient, _ = NewWebClient(&Config{Target: ts.URL + "/endless", Method: "GET", Stream: true, StreamDuration: 200 * time.Millisecond}, &RuntimeConfig{})
+
+	measure = webClient.DoMeasure(false)
+	if measure.IsFailure || !measure.Stream.Truncated || measure.Stream.Events == 0 || measure.Stream.Events > 10 {
+		t.Errorf("Endless stream should have been truncated: %s", measure.FailureCause)
+	}
+
+	webClient, _ = NewWebClient(&Config{Target: ts.URL + "/silent", Method: "GET", Stream: true, StreamDuration: time.Second}, &RuntimeConfig{})
+
+	measure = webClient.DoMeasure(false)
+	if !measure.IsFailure || measure.Stream.TimeToFirstEvent.IsValid() {
+		t.Errorf("Stream without events should have failed")
+	}
+}
+
+func TestStreamWithEnforcedProtocols(t *testing.T) {
+	for _, config := range []*Config{{HTTP10: true}, {HTTP2PriorKnowledge: true}, {H2CUpgrade: true}} {
+		config.Target = "http://localhost/events"
+		config.Method = "GET"
+		config.Stream = true
+		config.Wait = time.Second
+
+		if _, err := NewWebClient(config, &RuntimeConfig{}); err != nil {
+			t.Errorf("Stream client should have been built with %+v: %s", config, err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"net/url"
+	"strings"
+)
+
+// targetScheme returns the scheme of the target in lower case, the scheme selects the kind of ping which is done
+func targetScheme(target string) string {
+	parsedURL, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsedURL.Scheme)
+}
+
+func isTCPTarget(target string) bool {
+	return targetScheme(target) == "tcp"
+}
+
+func isTLSTarget(target string) bool {
+	return targetScheme(target) == "tls"
+}
+
+// isGRPCTarget returns true for grpc:// (cleartext) and grpcs:// (TLS) targets
+func isGRPCTarget(target string) bool {
+	switch targetScheme(target) {
+	case "grpc", "grpcs":
+		return true
+	default:
+		return false
+	}
+}
+
+// isWebSocketTarget returns true for ws:// (cleartext) and wss:// (TLS) targets
+func isWebSocketTarget(target string) bool {
+	switch targetScheme(target) {
+	case "ws", "wss":
+		return true
+	default:
+		return false
+	}
+}
+
+// isHTTPTarget returns true if HTTP requests are exchanged with the target, this is the case unless another kind of
+// ping is selected by the scheme of the target
+func isHTTPTarget(target string) bool {
+	switch targetScheme(target) {
+	case "tcp", "tls", "grpc", "grpcs", "ws", "wss":
+		return false
+	default:
+		return true
+	}
+}
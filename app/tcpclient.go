@@ -0,0 +1,173 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"errors"
+	"fever.ch/http-ping/net/sockettrace"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+)
+
+const protoTCP = "TCP"
+
+type tcpClientImpl struct {
+	config   *Config
+	url      *url.URL
+	addr     string
+	resolver *resolver
+	dialer   *net.Dialer
+}
+
+// NewTCPClient builds a client which measures the time required to establish TCP connections (without any exchange
+// of data), the target is expected to be in the form tcp://host:port
+func NewTCPClient(config *Config, _ *RuntimeConfig) (WebClient, error) {
+	return newTCPClient(config)
+}
+
+func newTCPClient(config *Config) (*tcpClientImpl, error) {
+	parsedURL, err := url.Parse(config.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	if parsedURL.Port() == "" {
+		return nil, fmt.Errorf("port missing in target %s", config.Target)
+	}
+
+	dialer, err := newDialer(config, "tcp")
+	if err != nil {
+		return nil, err
+	}
+
+	tcpClient := &tcpClientImpl{
+		config: config,
+		url:    parsedURL,
+		addr:   parsedURL.Host,
+		dialer: dialer,
+	}
+
+	if config.ConnTarget == "" {
+		tcpClient.resolver = newResolver(config)
+	} else {
+		tcpClient.addr = config.ConnTarget
+	}
+
+	return tcpClient, nil
+}
+
+func (tcpClient *tcpClientImpl) URL() string {
+	return tcpClient.url.String()
+}
+
+// DoMeasure opens a TCP connection to the target and closes it as soon as it is established
+func (tcpClient *tcpClientImpl) DoMeasure(_ bool) *HTTPMeasure {
+	return tcpClient.DoMeasureContext(context.Background(), false)
+}
+
+// DoMeasureContext does the same as DoMeasure, the connection is aborted if ctx is canceled
+func (tcpClient *tcpClientImpl) DoMeasureContext(ctx context.Context, _ bool) *HTTPMeasure {
+	totalTimer := newTimer()
+	dnsTimer := newTimer()
+	tcpTimer := newTimer()
+
+	ctx, cancel := tcpClient.context(ctx)
+	defer cancel()
+
+	totalTimer.start()
+	conn, err := tcpClient.connect(ctx, dnsTimer, tcpTimer)
+	totalTimer.stop()
+
+	if err != nil {
+		return &HTTPMeasure{
+			IsFailure:    true,
+			FailureCause: err.Error(),
+		}
+	}
+
+	remoteAddr := conn.RemoteAddr().String()
+	_ = conn.Close()
+
+	measure := &HTTPMeasure{
+		Proto:      protoTCP,
+		RemoteAddr: remoteAddr,
+
+		TotalTime:         totalTimer.measure(),
+		DNSResolution:     dnsTimer.measure(),
+		TCPHandshake:      tcpTimer.measure(),
+		ConnEstablishment: totalTimer.measure(),
+	}
+
+	if tracedConn, ok := conn.(sockettrace.TracedConn); ok {
+		measure.TCPInfo, _ = tracedConn.TCPInfo()
+	}
+
+	return measure
+}
+
+// context returns the context bounding a measure to the waiting time defined in the config
+func (tcpClient *tcpClientImpl) context(parent context.Context) (context.Context, context.CancelFunc) {
+	if tcpClient.config.Wait > 0 {
+		return context.WithTimeout(parent, tcpClient.config.Wait)
+	}
+	return context.WithCancel(parent)
+}
+
+// connect resolves the target (unless a connection target is enforced) and establishes a TCP connection with it
+func (tcpClient *tcpClientImpl) connect(ctx context.Context, dnsTimer, tcpTimer *timer) (net.Conn, error) {
+	addr := tcpClient.addr
+	if tcpClient.resolver != nil {
+		dnsTimer.start()
+		resolvedAddr, err := tcpClient.resolver.resolveConn(addr)
+		dnsTimer.stop()
+		if err != nil {
+			return nil, err
+		}
+		addr = resolvedAddr
+	}
+
+	ctx = sockettrace.WithTrace(ctx,
+		&sockettrace.ConnTrace{
+			TCPStart: func() {
+				tcpTimer.start()
+			},
+			TCPEstablished: func() {
+				tcpTimer.stop()
+			},
+		})
+
+	conn, err := sockettrace.NewSocketTrace(ctx, tcpClient.dialer, "tcp", addr)
+	if err != nil {
+		return nil, errors.New(tcpFailureCause(err))
+	}
+	return conn, nil
+}
+
+func tcpFailureCause(err error) string {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return "Connection refused (RST)"
+	} else if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return "Timeout"
+	} else if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return "Timeout"
+	}
+	return err.Error()
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPClient(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	addr := listener.Addr().String()
+
+	tcpClient, err := NewTCPClient(&Config{Target: fmt.Sprintf("tcp://%s", addr), Wait: time.Second}, &RuntimeConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	measure := tcpClient.DoMeasure(false)
+	if measure.IsFailure || measure.Proto != protoTCP || measure.RemoteAddr != addr || !measure.TCPHandshake.IsValid() {
+		t.Errorf("TCP connection should have been established: %s", measure.FailureCause)
+	}
+
+	_ = listener.Close()
+
+	measure = tcpClient.DoMeasure(false)
+	if !measure.IsFailure || measure.FailureCause != "Connection refused (RST)" {
+		t.Errorf("TCP connection should have been refused: %s", measure.FailureCause)
+	}
+}
+
+func TestTCPClientWithoutPort(t *testing.T) {
+	if _, err := NewTCPClient(&Config{Target: "tcp://127.0.0.1"}, &RuntimeConfig{}); err == nil {
+		t.Errorf("A port should be required")
+	}
+}
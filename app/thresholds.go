@@ -0,0 +1,70 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fever.ch/http-ping/stats"
+	"fmt"
+	"time"
+)
+
+// Threshold is a limit on a statistic of a run, the run fails if the statistic exceeds Max. The statistic is either
+// "loss" (Max is a percentage), "avg" or "p95" (Max is a number of milliseconds)
+type Threshold struct {
+	Statistic string
+	Max       float64
+}
+
+// ExitError is returned by HTTPPing.Run when the run is complete but did not go well (no answer, or a threshold has
+// been exceeded), Code is the exit code to use (1 like ping does)
+type ExitError struct {
+	Code   int
+	Reason string
+}
+
+func (e *ExitError) Error() string {
+	return e.Reason
+}
+
+// checkThresholds returns a description of each threshold exceeded by the run
+func checkThresholds(thresholds []Threshold, lossRate float64, latencies []stats.Measure) []string {
+	var tripped []string
+	for _, threshold := range thresholds {
+		// latencies can't be compared when there's no answer (the run fails anyway)
+		if threshold.Statistic != "loss" && len(latencies) == 0 {
+			continue
+		}
+
+		var value float64
+		unit := " ms"
+		switch threshold.Statistic {
+		case "loss":
+			value, unit = lossRate, "%"
+		case "avg":
+			value = stats.PingStatsFromLatencies(latencies).Average.ToFloat(time.Millisecond)
+		case "p95":
+			value = stats.Percentiles(latencies, 95)[0].ToFloat(time.Millisecond)
+		default:
+			tripped = append(tripped, fmt.Sprintf("unknown statistic %s", threshold.Statistic))
+			continue
+		}
+		if value > threshold.Max {
+			tripped = append(tripped, fmt.Sprintf("%s = %.1f%s > %.1f%s", threshold.Statistic, value, unit, threshold.Max, unit))
+		}
+	}
+	return tripped
+}
@@ -19,6 +19,7 @@ package app
 import (
 	"fever.ch/http-ping/stats"
 	"math"
+	"sync"
 	"time"
 )
 
@@ -27,18 +28,24 @@ var (
 	defaultStopTime  = time.UnixMicro(math.MinInt64)
 )
 
+// timer keeps the earliest start and the latest stop, it can be used by concurrent hooks (i.e. with HTTP/2, the end of
+// the request body may be written after the response has been received)
 type timer struct {
+	mutex               sync.Mutex
 	startTime, stopTime time.Time
 }
 
 func newTimer() *timer {
 	return &timer{
-		defaultStartTime,
-		defaultStopTime,
+		startTime: defaultStartTime,
+		stopTime:  defaultStopTime,
 	}
 }
 
 func (t *timer) start() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
 	ts := time.Now()
 	if ts.Before(t.startTime) {
 		t.startTime = ts
@@ -46,6 +53,9 @@ func (t *timer) start() {
 }
 
 func (t *timer) stop() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
 	ts := time.Now()
 	if ts.After(t.stopTime) {
 		t.stopTime = ts
@@ -57,6 +67,9 @@ func (t *timer) duration() time.Duration {
 }
 
 func (t *timer) measure() stats.Measure {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
 	if t.startTime == defaultStartTime || t.stopTime == defaultStopTime {
 		return stats.MeasureNotInitialized
 	}
@@ -0,0 +1,136 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"fever.ch/http-ping/net/sockettrace"
+)
+
+const protoTLS = "TLS"
+
+type tlsClientImpl struct {
+	*tcpClientImpl
+}
+
+// NewTLSClient builds a client which measures the time required to establish TLS sessions (TCP connection and TLS
+// handshake, without any exchange of data), the target is expected to be in the form tls://host:port. If StartTLS is
+// defined in config, the connection is upgraded to TLS with the STARTTLS dialogue of the corresponding protocol.
+func NewTLSClient(config *Config, _ *RuntimeConfig) (WebClient, error) {
+	tcpClient, err := newTCPClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return &tlsClientImpl{tcpClient}, nil
+}
+
+// tlsConfig offers only the protocols configured with ALPN, the target may not be a web server (i.e. SMTPS or LDAPS)
+// and servers enforcing ALPN abort handshakes offering protocols they don't speak
+func (tlsClient *tlsClientImpl) tlsConfig() *tls.Config {
+	return &tls.Config{
+		ServerName:         tlsClient.url.Hostname(),
+		InsecureSkipVerify: tlsClient.config.NoCheckCertificate,
+		NextProtos:         tlsClient.config.ALPN,
+	}
+}
+
+// DoMeasure opens a TCP connection to the target, does the TLS handshake and closes the connection
+func (tlsClient *tlsClientImpl) DoMeasure(_ bool) *HTTPMeasure {
+	return tlsClient.DoMeasureContext(context.Background(), false)
+}
+
+// DoMeasureContext does the same as DoMeasure, the connection is aborted if ctx is canceled
+func (tlsClient *tlsClientImpl) DoMeasureContext(ctx context.Context, _ bool) *HTTPMeasure {
+	totalTimer := newTimer()
+	connTimer := newTimer()
+	dnsTimer := newTimer()
+	tcpTimer := newTimer()
+	tlsTimer := newTimer()
+
+	ctx, cancel := tlsClient.context(ctx)
+	defer cancel()
+
+	totalTimer.start()
+	connTimer.start()
+	conn, err := tlsClient.connect(ctx, dnsTimer, tcpTimer)
+	if err != nil {
+		return &HTTPMeasure{
+			IsFailure:    true,
+			FailureCause: err.Error(),
+		}
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	startTLSTimer := newTimer()
+	if tlsClient.config.StartTLS != "" {
+		startTLSTimer.start()
+		err = startTLS(ctx, conn, tlsClient.config.StartTLS)
+		startTLSTimer.stop()
+		if err != nil {
+			return &HTTPMeasure{
+				IsFailure:    true,
+				FailureCause: err.Error(),
+			}
+		}
+	}
+
+	tlsConn := tls.Client(conn, tlsClient.tlsConfig())
+
+	tlsTimer.start()
+	err = tlsConn.HandshakeContext(ctx)
+	tlsTimer.stop()
+	connTimer.stop()
+	totalTimer.stop()
+
+	if err != nil {
+		return &HTTPMeasure{
+			IsFailure:    true,
+			FailureCause: err.Error(),
+		}
+	}
+
+	state := tlsConn.ConnectionState()
+
+	measure := &HTTPMeasure{
+		Proto:      protoTLS,
+		RemoteAddr: conn.RemoteAddr().String(),
+
+		TLSEnabled:      true,
+		TLSVersion:      tlsVersionName(state.Version),
+		TLSCipherSuite:  tls.CipherSuiteName(state.CipherSuite),
+		TLSALPN:         state.NegotiatedProtocol,
+		TLSCertificates: state.PeerCertificates,
+
+		TotalTime:         totalTimer.measure(),
+		DNSResolution:     dnsTimer.measure(),
+		TCPHandshake:      tcpTimer.measure(),
+		TLSDuration:       tlsTimer.measure(),
+		ConnEstablishment: connTimer.measure(),
+
+		StartTLS:         tlsClient.config.StartTLS,
+		StartTLSDuration: startTLSTimer.measure(),
+	}
+
+	if tracedConn, ok := conn.(sockettrace.TracedConn); ok {
+		measure.TCPInfo, _ = tracedConn.TCPInfo()
+	}
+
+	return measure
+}
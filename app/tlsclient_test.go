@@ -0,0 +1,67 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTLSClient(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nil)
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	target := strings.Replace(ts.URL, "https://", "tls://", 1)
+
+	tlsClient, err := NewTLSClient(&Config{Target: target, Wait: time.Second, NoCheckCertificate: true}, &RuntimeConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	measure := tlsClient.DoMeasure(false)
+	if measure.IsFailure || measure.Proto != protoTLS || !measure.TLSDuration.IsValid() {
+		t.Fatalf("TLS handshake should have succeed: %s", measure.FailureCause)
+	}
+
+	if measure.TLSVersion != "TLS-1.3" || measure.TLSCipherSuite == "" || measure.TLSALPN != "" || len(measure.TLSCertificates) == 0 {
+		t.Errorf("TLS session details are missing")
+	}
+
+	tlsClient, _ = NewTLSClient(&Config{Target: target, Wait: time.Second, NoCheckCertificate: true, ALPN: []string{"h2", "http/1.1"}}, &RuntimeConfig{})
+
+	if measure = tlsClient.DoMeasure(false); measure.IsFailure || measure.TLSALPN != "h2" {
+		t.Errorf("h2 should have been negotiated with ALPN: %s", measure.FailureCause)
+	}
+
+	// servers enforcing ALPN accept handshakes without any protocol offered
+	ts.TLS.NextProtos = []string{"smtp"}
+	tlsClient, _ = NewTLSClient(&Config{Target: target, Wait: time.Second, NoCheckCertificate: true}, &RuntimeConfig{})
+
+	if measure = tlsClient.DoMeasure(false); measure.IsFailure {
+		t.Errorf("TLS handshake without ALPN should have succeed: %s", measure.FailureCause)
+	}
+
+	tlsClient, _ = NewTLSClient(&Config{Target: target, Wait: time.Second}, &RuntimeConfig{})
+
+	if measure = tlsClient.DoMeasure(false); !measure.IsFailure {
+		t.Errorf("TLS handshake should have failed (unknown authority)")
+	}
+}
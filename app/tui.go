@@ -0,0 +1,275 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"fever.ch/http-ping/stats"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// tuiWindow is the number of requests on which the rolling statistics of the dashboard are computed
+	tuiWindow = 60
+	// tuiBars is the number of requests whose phases are drawn
+	tuiBars = 10
+	// tuiBarWidth is the width of the longest phase bar
+	tuiBarWidth = 50
+	// tuiErrors is the number of errors kept on screen
+	tuiErrors = 5
+
+	ansiClear = "\033[H\033[2J"
+	ansiReset = "\033[0m"
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// tuiPhases are the phases shown in the stacked bars, with their ANSI color
+var tuiPhases = []struct {
+	label string
+	color int
+}{
+	{"dns", 36},
+	{"connect", 34},
+	{"tls", 35},
+	{"send", 33},
+	{"wait", 32},
+	{"receive", 31},
+}
+
+type tuiRequest struct {
+	id      int
+	latency stats.Measure
+	phases  []stats.Measure
+}
+
+// tuiLogger redraws a dashboard with the rolling statistics of the last requests after each measure
+type tuiLogger struct {
+	config  *Config
+	stdout  io.Writer
+	url     string
+	summary Observer
+	start   time.Time
+
+	attempts  int64
+	successes int64
+	requests  []*tuiRequest
+	codes     map[string]int64
+	errors    []string
+}
+
+func newTUILogger(config *Config, stdout io.Writer) Observer {
+	return &tuiLogger{config: config, stdout: stdout, summary: newStandardLogger(config, stdout), codes: make(map[string]int64)}
+}
+
+func (tuiLogger *tuiLogger) OnStart(url string, _ string) {
+	tuiLogger.url = url
+	tuiLogger.start = time.Now()
+}
+
+// OnRedirect does nothing, the screen is redrawn anyway
+func (tuiLogger *tuiLogger) OnRedirect(_ string) {
+}
+
+func (tuiLogger *tuiLogger) OnMeasure(measure *HTTPMeasure, id int) {
+	tuiLogger.attempts++
+
+	request := &tuiRequest{id: id, latency: stats.MeasureNotValid}
+	if measure.IsFailure {
+		tuiLogger.errors = append(tuiLogger.errors, fmt.Sprintf("%8d: %s %s", id, time.Now().Format("15:04:05"), measure.FailureCause))
+		if len(tuiLogger.errors) > tuiErrors {
+			tuiLogger.errors = tuiLogger.errors[1:]
+		}
+	} else {
+		tuiLogger.successes++
+		request.latency = measure.TotalTime
+		request.phases = measurePhases(measure)
+	}
+	tuiLogger.requests = append(tuiLogger.requests, request)
+	if len(tuiLogger.requests) > tuiWindow {
+		tuiLogger.requests = tuiLogger.requests[1:]
+	}
+
+	switch {
+	case measure.StatusCode != 0:
+		tuiLogger.codes[fmt.Sprintf("%d", measure.StatusCode)]++
+	case measure.IsFailure:
+		tuiLogger.codes["error"]++
+	default:
+		tuiLogger.codes["ok"]++
+	}
+
+	var screen bytes.Buffer
+	tuiLogger.draw(&screen)
+	_, _ = fmt.Fprintf(tuiLogger.stdout, "%s%s", ansiClear, screen.String())
+}
+
+func (tuiLogger *tuiLogger) OnClose(summary *Summary) {
+	tuiLogger.summary.OnClose(summary)
+}
+
+// measurePhases returns the durations of the phases of a measure in the order of tuiPhases, 0 for the missing ones
+func measurePhases(measure *HTTPMeasure) []stats.Measure {
+	valid := func(m stats.Measure) stats.Measure {
+		if m.IsSuccess() {
+			return m
+		}
+		return 0
+	}
+	dns := valid(measure.DNSResolution)
+	tls := valid(measure.TLSDuration)
+	connect := valid(measure.ConnEstablishment) - dns - tls
+	if connect < 0 {
+		connect = 0
+	}
+	return []stats.Measure{dns, connect, tls, valid(measure.RequestSending), valid(measure.Wait), valid(measure.ResponseIngesting)}
+}
+
+func (tuiLogger *tuiLogger) draw(screen io.Writer) {
+	var latencies []stats.Measure
+	var failures int
+	for _, request := range tuiLogger.requests {
+		latencies = append(latencies, request.latency)
+		if !request.latency.IsValid() {
+			failures++
+		}
+	}
+
+	_, _ = fmt.Fprintf(screen, "HTTP-PING %s (%s)\n\n", tuiLogger.url, time.Since(tuiLogger.start).Truncate(time.Second))
+
+	_, _ = fmt.Fprintf(screen, "%d requests sent, %d answers received, %.1f%% loss (last %d: %.1f%% loss)\n\n",
+		tuiLogger.attempts, tuiLogger.successes, float64(100*(tuiLogger.attempts-tuiLogger.successes))/float64(tuiLogger.attempts),
+		len(latencies), float64(100*failures)/float64(len(latencies)))
+
+	_, _ = fmt.Fprintf(screen, "latency of the last %d requests:\n", len(latencies))
+	_, _ = fmt.Fprintf(screen, "  %s\n", sparkline(latencies))
+	if failures < len(latencies) {
+		ms := func(d stats.Measure) float64 {
+			return d.ToFloat(time.Millisecond)
+		}
+		p := stats.Percentiles(latencies, 50, 90, 95, 99)
+		pingStats := stats.PingStatsFromLatencies(latencies)
+		_, _ = fmt.Fprintf(screen, "  min/avg/max = %.1f/%.1f/%.1f ms, p50/p90/p95/p99 = %.1f/%.1f/%.1f/%.1f ms\n",
+			ms(pingStats.Min), ms(pingStats.Average), ms(pingStats.Max), ms(p[0]), ms(p[1]), ms(p[2]), ms(p[3]))
+	}
+
+	_, _ = fmt.Fprintf(screen, "\nphases of the last requests:")
+	for _, phase := range tuiPhases {
+		_, _ = fmt.Fprintf(screen, " \033[%dm█%s %s", phase.color, ansiReset, phase.label)
+	}
+	_, _ = fmt.Fprintf(screen, "\n")
+	tuiLogger.drawPhases(screen)
+
+	_, _ = fmt.Fprintf(screen, "\nstatus codes:\n")
+	var codes []string
+	for code := range tuiLogger.codes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		ratio := float64(tuiLogger.codes[code]) / float64(tuiLogger.attempts)
+		_, _ = fmt.Fprintf(screen, "  %-5s %8d %5.1f%% %s\n", code, tuiLogger.codes[code], 100*ratio,
+			strings.Repeat("■", int(math.Round(ratio*tuiBarWidth/2))))
+	}
+
+	if len(tuiLogger.errors) > 0 {
+		_, _ = fmt.Fprintf(screen, "\nlast errors:\n")
+		for _, e := range tuiLogger.errors {
+			_, _ = fmt.Fprintf(screen, "%s\n", e)
+		}
+	}
+}
+
+// drawPhases draws the phases of the last successful requests as stacked bars scaled on the longest one
+func (tuiLogger *tuiLogger) drawPhases(screen io.Writer) {
+	var requests []*tuiRequest
+	for i := len(tuiLogger.requests) - 1; i >= 0 && len(requests) < tuiBars; i-- {
+		if tuiLogger.requests[i].phases != nil {
+			requests = append([]*tuiRequest{tuiLogger.requests[i]}, requests...)
+		}
+	}
+
+	var longest stats.Measure
+	for _, request := range requests {
+		var total stats.Measure
+		for _, phase := range request.phases {
+			total += phase
+		}
+		if total > longest {
+			longest = total
+		}
+	}
+
+	for _, request := range requests {
+		_, _ = fmt.Fprintf(screen, "%8d: ", request.id)
+		var sum stats.Measure
+		drawn := 0
+		for i, phase := range request.phases {
+			sum += phase
+			// rounding the cumulated durations avoids the drift of the rounding of each phase
+			width := 0
+			if longest > 0 {
+				width = int(math.Round(float64(sum)/float64(longest)*tuiBarWidth)) - drawn
+			}
+			if width > 0 {
+				_, _ = fmt.Fprintf(screen, "\033[%dm%s%s", tuiPhases[i].color, strings.Repeat("█", width), ansiReset)
+				drawn += width
+			}
+		}
+		_, _ = fmt.Fprintf(screen, " %.1f ms\n", request.latency.ToFloat(time.Millisecond))
+	}
+}
+
+// sparkline draws the latencies scaled between the fastest and the slowest one, failures are shown as ×
+func sparkline(latencies []stats.Measure) string {
+	min, max := stats.Measure(math.MaxInt64), stats.Measure(0)
+	for _, latency := range latencies {
+		if latency.IsValid() {
+			if latency < min {
+				min = latency
+			}
+			if latency > max {
+				max = latency
+			}
+		}
+	}
+
+	var line strings.Builder
+	for _, latency := range latencies {
+		switch {
+		case !latency.IsValid():
+			line.WriteRune('×')
+		case max == min:
+			line.WriteRune(sparks[0])
+		default:
+			// computed in floating point, the product would overflow the int of 32-bit platforms
+			index := int(float64(latency-min) / float64(max-min) * float64(len(sparks)-1))
+			if index < 0 {
+				index = 0
+			} else if index >= len(sparks) {
+				index = len(sparks) - 1
+			}
+			line.WriteRune(sparks[index])
+		}
+	}
+	return line.String()
+}
@@ -0,0 +1,59 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+)
+
+// newRequestBody returns the provider of the request bodies defined in config (a string, a file or random bytes),
+// nil if requests have no body
+func newRequestBody(config *Config) func() (io.Reader, int64, error) {
+	switch {
+	case config.UploadFile != "":
+		// the file is read again for each request, it's not kept in memory
+		return func() (io.Reader, int64, error) {
+			file, err := os.Open(config.UploadFile)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			info, err := file.Stat()
+			if err != nil {
+				_ = file.Close()
+				return nil, 0, err
+			}
+			return file, info.Size(), nil
+		}
+	case config.UploadSize > 0:
+		// random bytes can't be compressed on the way
+		return func() (io.Reader, int64, error) {
+			random := rand.New(rand.NewSource(time.Now().UnixNano()))
+			return io.LimitReader(random, config.UploadSize), config.UploadSize, nil
+		}
+	case config.Data != "":
+		return func() (io.Reader, int64, error) {
+			return strings.NewReader(config.Data), int64(len(config.Data)), nil
+		}
+	default:
+		return nil
+	}
+}
@@ -17,11 +17,14 @@
 package app
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fever.ch/http-ping/net/sockettrace"
+	"fever.ch/http-ping/stats"
 	"fmt"
+	"golang.org/x/net/proxy"
 	"io"
 	"io/ioutil"
 	"net"
@@ -30,6 +33,7 @@ import (
 	"net/http/httptrace"
 	"net/url"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -46,6 +50,22 @@ type WebClient interface {
 	URL() string
 }
 
+// ContextWebClient is a WebClient whose measures can be aborted, all the clients of this package implement it
+type ContextWebClient interface {
+	WebClient
+
+	// DoMeasureContext does the same as DoMeasure, the request is aborted if ctx is canceled
+	DoMeasureContext(ctx context.Context, followRedirect bool) *HTTPMeasure
+}
+
+// doMeasure does a measure with client, ctx is ignored if client doesn't implement ContextWebClient
+func doMeasure(ctx context.Context, client WebClient, followRedirect bool) *HTTPMeasure {
+	if contextClient, ok := client.(ContextWebClient); ok {
+		return contextClient.DoMeasureContext(ctx, followRedirect)
+	}
+	return client.DoMeasure(followRedirect)
+}
+
 type webClientImpl struct {
 	httpClient    *http.Client
 	connTarget    string
@@ -54,8 +74,27 @@ type webClientImpl struct {
 	url           *url.URL
 	resolver      *resolver
 
+	// transport is the transport of net/http, the client may use it through the transport of an enforced protocol
+	transport *http.Transport
+
 	writes int64
 	reads  int64
+
+	connsMutex sync.Mutex
+	conns      map[string]sockettrace.TracedConn
+
+	socksDialer proxy.ContextDialer
+	socksProxy  string
+
+	// requestBody returns the body of the next request and its size (-1 if unknown), requests have no body if it's not
+	// set
+	requestBody func() (io.Reader, int64, error)
+	// checkResponse inspects the responses in order to complete the measures, bodies are only kept if it's set
+	checkResponse func(res *http.Response, body []byte, measure *HTTPMeasure)
+	// prepareRequest completes the requests, after the settings of the configuration have been applied
+	prepareRequest func(req *http.Request)
+	// upgrade takes over the connections switching protocols, they are dropped if it's not set
+	upgrade func(res *http.Response, conn io.ReadWriteCloser, measure *HTTPMeasure)
 }
 
 func init() {
@@ -64,7 +103,10 @@ func init() {
 }
 
 func updateConnTarget(webClient *webClientImpl) {
-	if webClient.config.ConnTarget == "" {
+	if webClient.config.UnixSocket != "" {
+		// connections are established with the Unix socket, whatever the host of the target is
+		webClient.connTarget = webClient.config.UnixSocket
+	} else if webClient.config.ConnTarget == "" {
 		webClient.resolver = newResolver(webClient.config)
 
 		webClient.connTarget = webClient.url.Hostname()
@@ -87,7 +129,7 @@ func updateConnTarget(webClient *webClientImpl) {
 
 // NewWebClient builds a new instance of webClientImpl which will provides functions for Http-Ping
 func NewWebClient(config *Config, runtimeConfig *RuntimeConfig) (WebClient, error) {
-	webClient := webClientImpl{config: config, runtimeConfig: runtimeConfig}
+	webClient := webClientImpl{config: config, runtimeConfig: runtimeConfig, conns: make(map[string]sockettrace.TracedConn)}
 	parsedURL, err := url.Parse(config.Target)
 	if err != nil {
 		return nil, err
@@ -96,28 +138,45 @@ func NewWebClient(config *Config, runtimeConfig *RuntimeConfig) (WebClient, erro
 
 	updateConnTarget(&webClient)
 
-	dialer := &net.Dialer{}
+	dialer, err := newDialer(config, "tcp")
+	if err != nil {
+		return nil, err
+	}
 
-	startDNSHook := func(ctx context.Context) {
-		trace := httptrace.ContextClientTrace(ctx)
-		if trace != nil || trace.DNSStart != nil {
-			trace.DNSStart(httptrace.DNSStartInfo{})
-		}
+	proxyFunc, err := newProxyFunc(config)
+	if err != nil {
+		return nil, err
 	}
 
-	stopDNSHook := func(ctx context.Context) {
-		trace := httptrace.ContextClientTrace(ctx)
-		if trace != nil || trace.DNSDone != nil {
-			trace.DNSDone(httptrace.DNSDoneInfo{})
+	if config.SOCKS5 != "" {
+		webClient.socksDialer, webClient.socksProxy, err = newSOCKSDialer(config, &socksForwardDialer{webClient: &webClient, dialer: dialer})
+		if err != nil {
+			return nil, err
 		}
 	}
 
 	dialCtx := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if webClient.config.UnixSocket != "" {
+			return sockettrace.NewSocketTrace(ctx, dialer, "unix", webClient.connTarget)
+		}
+
+		if webClient.socksDialer != nil {
+			return webClient.dialSOCKS(ctx, network)
+		}
+
 		var ipaddr string
 
 		startDNSHook(ctx)
 
-		if webClient.config.ConnTarget == "" {
+		if trace := contextProxyTrace(ctx); trace != nil && trace.proxyURL != nil {
+			// the connection is established with the proxy, not with the target
+			resolvedIpaddr, err := webClient.proxyResolver().resolveConn(addr)
+
+			if err != nil {
+				return nil, err
+			}
+			ipaddr = resolvedIpaddr
+		} else if webClient.config.ConnTarget == "" {
 			resolvedIpaddr, err := webClient.resolver.resolveConn(webClient.connTarget)
 
 			if err != nil {
@@ -129,33 +188,135 @@ func NewWebClient(config *Config, runtimeConfig *RuntimeConfig) (WebClient, erro
 		}
 		stopDNSHook(ctx)
 
-		return sockettrace.NewSocketTrace(ctx, dialer, network, ipaddr)
+		conn, err := sockettrace.NewSocketTrace(ctx, dialer, network, ipaddr)
+		if err != nil {
+			return nil, err
+		}
+		webClient.registerConn(conn)
+		return conn, nil
 	}
 
-	webClient.httpClient = &http.Client{
-		Timeout: webClient.config.Wait,
-		Transport: &http.Transport{
-			Proxy:       http.ProxyFromEnvironment,
-			DialContext: dialCtx,
+	transport := &http.Transport{
+		Proxy:                 proxyFunc,
+		GetProxyConnectHeader: getProxyConnectHeader,
+		DialContext:           dialCtx,
 
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: config.NoCheckCertificate,
-			},
-			DisableCompression: config.DisableCompression,
-			ForceAttemptHTTP2:  !webClient.config.DisableHTTP2,
-			MaxIdleConns:       10,
-			DisableKeepAlives:  config.DisableKeepAlive,
-			IdleConnTimeout:    config.Interval + config.Wait,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: config.NoCheckCertificate,
 		},
+		DisableCompression: config.DisableCompression,
+		ForceAttemptHTTP2:  !webClient.config.DisableHTTP2,
+		MaxIdleConns:       10,
+		DisableKeepAlives:  config.DisableKeepAlive,
+		IdleConnTimeout:    config.Interval + config.Wait,
+	}
+
+	if webClient.config.DisableHTTP2 || webClient.config.HTTP10 {
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
 	}
 
-	if webClient.config.DisableHTTP2 {
-		webClient.httpClient.Transport.(*http.Transport).TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	webClient.transport = transport
+	webClient.httpClient = &http.Client{
+		Timeout:   webClient.config.Wait,
+		Transport: transport,
+	}
+
+	if webClient.config.HTTP10 {
+		webClient.httpClient.Transport = &http10Transport{rawTransport{dial: dialCtx, tlsConfig: transport.TLSClientConfig}}
+	} else if webClient.config.HTTP2PriorKnowledge {
+		webClient.httpClient.Transport = newH2CPriorKnowledgeTransport(config, dialCtx, transport)
+	} else if webClient.config.H2CUpgrade {
+		webClient.httpClient.Transport = &h2cUpgradeTransport{rawTransport: rawTransport{dial: dialCtx, tlsConfig: transport.TLSClientConfig}, fallback: transport}
+	}
+
+	webClient.requestBody = newRequestBody(config)
+
+	// streams are read for a limited time, whatever the timeout is
+	if config.Stream {
+		webClient.useSetupTimeouts()
 	}
 
 	return &webClient, nil
 }
 
+// useSetupTimeouts replaces the timeout of the HTTP client, which also bounds the reading of the response bodies, by
+// timeouts on the steps leading to the headers of the responses
+func (webClient *webClientImpl) useSetupTimeouts() {
+	wait := webClient.config.Wait
+
+	webClient.httpClient.Timeout = 0
+	transport := webClient.transport
+	transport.TLSHandshakeTimeout = wait
+	transport.ResponseHeaderTimeout = wait
+
+	if wait > 0 {
+		dial := transport.DialContext
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			ctx, cancel := context.WithTimeout(ctx, wait)
+			defer cancel()
+			return dial(ctx, network, addr)
+		}
+	}
+}
+
+func startDNSHook(ctx context.Context) {
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{})
+	}
+}
+
+func stopDNSHook(ctx context.Context) {
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSDone != nil {
+		trace.DNSDone(httptrace.DNSDoneInfo{})
+	}
+}
+
+// proxyResolver returns the resolver used to resolve the address of proxies
+func (webClient *webClientImpl) proxyResolver() *resolver {
+	if webClient.resolver == nil {
+		return newResolver(webClient.config)
+	}
+	return webClient.resolver
+}
+
+// registerConn keeps track of the connections opened by the client, in order to get their TCP statistics later
+func (webClient *webClientImpl) registerConn(conn net.Conn) {
+	if tracedConn, ok := conn.(sockettrace.TracedConn); ok {
+		webClient.connsMutex.Lock()
+		webClient.conns[conn.LocalAddr().String()] = tracedConn
+		webClient.connsMutex.Unlock()
+	}
+}
+
+// tcpInfo returns the TCP statistics of the connection bound to localAddr, connections which have been closed are
+// forgotten afterwards
+func (webClient *webClientImpl) tcpInfo(localAddr string) *sockettrace.TCPInfo {
+	webClient.connsMutex.Lock()
+	defer webClient.connsMutex.Unlock()
+
+	var info *sockettrace.TCPInfo
+	if conn, ok := webClient.conns[localAddr]; ok {
+		info, _ = conn.TCPInfo()
+	}
+
+	for addr, conn := range webClient.conns {
+		if conn.Closed() {
+			delete(webClient.conns, addr)
+		}
+	}
+	return info
+}
+
+func tlsVersionName(version uint16) string {
+	code := int(version) - 0x0301
+	if code >= 0 {
+		return fmt.Sprintf("TLS-1.%d", code)
+	}
+	return "SSL-3"
+}
+
 func (webClient *webClientImpl) URL() string {
 	return webClient.url.String()
 }
@@ -202,10 +363,42 @@ func (webClient *webClientImpl) prepareReq(req *http.Request) {
 		}
 	}
 
+	if webClient.prepareRequest != nil {
+		webClient.prepareRequest(req)
+	}
+
+}
+
+// readBody copies the body of a response to sink, the progress is reported if needed and the body is cut after the
+// maximal count of bytes to be read (if any)
+func (webClient *webClientImpl) readBody(sink io.Writer, body io.Reader) (int64, bool, error) {
+	if webClient.runtimeConfig.ProgressCallBack != nil {
+		body = newProgressReader(body, webClient.runtimeConfig.ProgressCallBack)
+	}
+
+	maxBytes := webClient.config.MaxBytes
+	if maxBytes <= 0 {
+		s, err := io.Copy(sink, body)
+		return s, false, err
+	}
+
+	s, err := io.Copy(sink, io.LimitReader(body, maxBytes))
+	if err != nil || s < maxBytes {
+		return s, false, err
+	}
+
+	// the body is truncated if there's something left to be read
+	_, err = io.ReadFull(body, make([]byte, 1))
+	return s, err == nil, nil
 }
 
 // DoMeasure evaluates the latency to a specific HTTP/S server
 func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
+	return webClient.DoMeasureContext(context.Background(), followRedirect)
+}
+
+// DoMeasureContext evaluates the latency to a specific HTTP/S server, the request is aborted if ctx is canceled
+func (webClient *webClientImpl) DoMeasureContext(ctx context.Context, followRedirect bool) *HTTPMeasure {
 
 	if followRedirect {
 		webClient.httpClient.CheckRedirect = webClient.checkRedirectFollow
@@ -215,7 +408,22 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 		}
 	}
 
-	req, _ := http.NewRequest(webClient.config.Method, webClient.config.Target, nil)
+	var body io.Reader
+	var bodySize int64
+	if webClient.requestBody != nil {
+		var err error
+		if body, bodySize, err = webClient.requestBody(); err != nil {
+			return &HTTPMeasure{
+				IsFailure:    true,
+				FailureCause: err.Error(),
+			}
+		}
+	}
+
+	req, _ := http.NewRequest(webClient.config.Method, webClient.config.Target, body)
+	if body != nil {
+		req.ContentLength = bodySize
+	}
 
 	if webClient.httpClient.Jar == nil || !webClient.config.KeepCookies {
 		jar, _ := cookiejar.New(nil)
@@ -228,8 +436,16 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 		webClient.httpClient.Jar = jar
 	}
 
+	var stream *streamReader
+	if webClient.config.Stream {
+		stream = newStreamReader(webClient.config)
+	}
+
 	var reused bool
+	// the bytes written to the network before the connection is ready (i.e. handshakes) aren't part of the request
+	var writesAtConn int64
 	var remoteAddr string
+	var localAddr string
 
 	totalTimer := newTimer()
 	connTimer := newTimer()
@@ -240,13 +456,35 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 	waitTimer := newTimer()
 	responseTimer := newTimer()
 
+	proxyConnTimer := newTimer()
+	proxyTLSTimer := newTimer()
+	proxy := &proxyTrace{tunnelTimer: newTimer(), socksTimer: newTimer()}
+
+	// with an HTTPS proxy, the first TLS handshake is done with the proxy
+	isProxyTLSHandshake := func(handshakes int) bool {
+		return proxy.proxyURL != nil && proxy.proxyURL.Scheme == "https" && handshakes == 0
+	}
+	tlsHandshakesStarted, tlsHandshakesDone := 0, 0
+
 	clientTrace := &httptrace.ClientTrace{
 		TLSHandshakeStart: func() {
-			tlsTimer.start()
+			if isProxyTLSHandshake(tlsHandshakesStarted) {
+				proxyTLSTimer.start()
+			} else {
+				proxy.tunnelTimer.stop()
+				tlsTimer.start()
+			}
+			tlsHandshakesStarted++
 		},
 
 		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
-			tlsTimer.stop()
+			if isProxyTLSHandshake(tlsHandshakesDone) {
+				proxyTLSTimer.stop()
+				proxyConnTimer.stop()
+			} else {
+				tlsTimer.stop()
+			}
+			tlsHandshakesDone++
 		},
 		DNSStart: func(info httptrace.DNSStartInfo) {
 			dnsTimer.start()
@@ -262,8 +500,10 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 
 		GotConn: func(info httptrace.GotConnInfo) {
 			remoteAddr = info.Conn.RemoteAddr().String()
+			localAddr = info.Conn.LocalAddr().String()
 			connTimer.stop()
 			reqTimer.start()
+			atomic.StoreInt64(&writesAtConn, atomic.LoadInt64(&webClient.writes))
 			reused = info.Reused
 		},
 
@@ -275,10 +515,13 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 		GotFirstResponseByte: func() {
 			waitTimer.stop()
 			responseTimer.start()
+			if stream != nil {
+				stream.gotFirstByte()
+			}
 		},
 	}
 
-	ctx := sockettrace.WithTrace(context.Background(),
+	ctx = sockettrace.WithTrace(ctx,
 		&sockettrace.ConnTrace{
 			Read: func(i int) {
 				atomic.AddInt64(&webClient.reads, int64(i))
@@ -288,12 +531,16 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 			},
 			TCPStart: func() {
 				tcpTimer.start()
+				proxyConnTimer.start()
 			},
 			TCPEstablished: func() {
 				tcpTimer.stop()
+				proxyConnTimer.stop()
 			},
 		})
 
+	ctx = withProxyTrace(ctx, proxy)
+
 	traceCtx := httptrace.WithClientTrace(ctx, clientTrace)
 
 	req = req.WithContext(traceCtx)
@@ -301,6 +548,9 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 	webClient.prepareReq(req)
 
 	totalTimer.start()
+	if stream != nil {
+		stream.requestStarted()
+	}
 	res, err := webClient.httpClient.Do(req)
 
 	if err != nil {
@@ -310,15 +560,34 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 		}
 	}
 
-	s, err := io.Copy(ioutil.Discard, res.Body)
-	if err != nil {
-		return &HTTPMeasure{
-			IsFailure:    true,
-			FailureCause: "I/O error while reading payload",
+	var responseBody bytes.Buffer
+	var s int64
+	var truncated bool
+	var upgraded io.ReadWriteCloser
+
+	if conn, ok := res.Body.(io.ReadWriteCloser); ok && res.StatusCode == http.StatusSwitchingProtocols && webClient.upgrade != nil {
+		// the connection now belongs to the new protocol, there's no payload to be read
+		upgraded = conn
+	} else {
+		var sink io.Writer = ioutil.Discard
+		if webClient.checkResponse != nil {
+			sink = &responseBody
+		}
+
+		if stream != nil {
+			s, err = stream.consume(res.Body)
+		} else {
+			s, truncated, err = webClient.readBody(sink, res.Body)
+		}
+		if err != nil {
+			return &HTTPMeasure{
+				IsFailure:    true,
+				FailureCause: "I/O error while reading payload",
+			}
 		}
-	}
 
-	_ = res.Body.Close()
+		_ = res.Body.Close()
+	}
 	responseTimer.stop()
 	totalTimer.stop()
 
@@ -333,28 +602,41 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 	i := atomic.SwapInt64(&webClient.reads, 0)
 	o := atomic.SwapInt64(&webClient.writes, 0)
 
-	var tlsVersion string
+	var tlsVersion, tlsCipherSuite, tlsALPN string
 	if res.TLS != nil {
-		code := int(res.TLS.Version) - 0x0301
-		if code >= 0 {
-			tlsVersion = fmt.Sprintf("TLS-1.%d", code)
-		} else {
-			tlsVersion = "SSL-3"
-		}
+		tlsVersion = tlsVersionName(res.TLS.Version)
+		tlsCipherSuite = tls.CipherSuiteName(res.TLS.CipherSuite)
+		tlsALPN = res.TLS.NegotiatedProtocol
 	}
 
-	return &HTTPMeasure{
+	var proxyHost string
+	proxyConnection := stats.MeasureNotInitialized
+	if proxy.proxyURL != nil {
+		proxyHost = proxy.proxyURL.Host
+		proxyConnection = proxyConnTimer.measure()
+	} else if proxy.socksProxy != "" {
+		proxyHost = proxy.socksProxy
+		proxyConnection = proxyConnTimer.measure()
+	}
+
+	measure := &HTTPMeasure{
 		Proto:        res.Proto,
 		TotalTime:    totalTimer.measure(),
 		StatusCode:   res.StatusCode,
 		Bytes:        s,
+		Truncated:    truncated,
 		InBytes:      i,
 		OutBytes:     o,
+		RequestBytes: bodySize,
+		SentBytes:    o - atomic.LoadInt64(&writesAtConn),
 		SocketReused: reused,
 		Compressed:   !res.Uncompressed,
 		TLSEnabled:   res.TLS != nil,
 		TLSVersion:   tlsVersion,
 
+		TLSCipherSuite: tlsCipherSuite,
+		TLSALPN:        tlsALPN,
+
 		DNSResolution:     dnsTimer.measure(),
 		TCPHandshake:      tcpTimer.measure(),
 		TLSDuration:       tlsTimer.measure(),
@@ -363,11 +645,32 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 		Wait:              waitTimer.measure(),
 		ResponseIngesting: responseTimer.measure(),
 
+		Proxy:            proxyHost,
+		ProxyConnection:  proxyConnection,
+		ProxyTLSDuration: proxyTLSTimer.measure(),
+		ProxyTunnel:      proxy.tunnelTimer.measure(),
+		SOCKSNegotiation: proxy.socksTimer.measure(),
+
 		RemoteAddr: remoteAddr,
+		TCPInfo:    webClient.tcpInfo(localAddr),
+		UnixSocket: webClient.config.UnixSocket != "",
 
 		IsFailure:    failed,
 		FailureCause: failureCause,
 		Headers:      &res.Header,
 	}
 
+	if webClient.checkResponse != nil {
+		webClient.checkResponse(res, responseBody.Bytes(), measure)
+	}
+
+	if upgraded != nil {
+		webClient.upgrade(res, upgraded, measure)
+	}
+
+	if stream != nil {
+		stream.complete(measure)
+	}
+
+	return measure
 }
@@ -17,10 +17,17 @@
 package app
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 )
 
 func TestWithEmbeddedWebServer(t *testing.T) {
@@ -65,3 +72,214 @@ func TestWithEmbeddedWebServer(t *testing.T) {
 	}
 
 }
+
+func TestWithSourceIP(t *testing.T) {
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.RemoteAddr))
+		}))
+	defer ts.Close()
+
+	webClient, err := NewWebClient(&Config{Target: ts.URL, SourceIP: "127.0.0.1"}, &RuntimeConfig{})
+	if err != nil {
+		t.Fatalf("web client should have been built: %s", err)
+	}
+
+	if measure := webClient.DoMeasure(false); measure.IsFailure {
+		t.Errorf("Request from 127.0.0.1 should have succeed: %s", measure.FailureCause)
+	}
+
+	if _, err := NewWebClient(&Config{Target: ts.URL, SourceIP: "localhost"}, &RuntimeConfig{}); err == nil {
+		t.Errorf("Invalid source IP should have been rejected")
+	}
+}
+
+func TestTCPInfo(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("TCP_INFO is only supported on Linux")
+	}
+
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("Hello"))
+		}))
+	defer ts.Close()
+
+	webClient, _ := NewWebClient(&Config{Target: ts.URL, DisableKeepAlive: true}, &RuntimeConfig{})
+
+	for i := 0; i < 3; i++ {
+		measure := webClient.DoMeasure(false)
+		if measure.IsFailure || measure.TCPInfo == nil || measure.TCPInfo.MSS == 0 {
+			t.Fatalf("TCP statistics should have been gathered: %v", measure.TCPInfo)
+		}
+	}
+}
+
+func testWithUnixSocket(t *testing.T, target string) {
+	socket := filepath.Join(t.TempDir(), "http-ping.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("Unix sockets not available: %s", err)
+	}
+
+	ts := httptest.NewUnstartedServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodConnect || r.URL.IsAbs() {
+				w.WriteHeader(http.StatusMisdirectedRequest)
+				return
+			}
+			_, _ = w.Write([]byte("Hello"))
+		}))
+	ts.Listener = listener
+	ts.Start()
+	defer ts.Close()
+
+	webClient, _ := NewWebClient(&Config{Target: target, UnixSocket: socket}, &RuntimeConfig{})
+
+	for i := 0; i < 2; i++ {
+		measure := webClient.DoMeasure(false)
+		if measure.IsFailure || measure.StatusCode != 200 {
+			t.Fatalf("Request through the Unix socket should have succeed: %s (code=%d)", measure.FailureCause, measure.StatusCode)
+		}
+
+		if !measure.UnixSocket || measure.DNSResolution.IsValid() || !measure.TotalTime.IsValid() || measure.InBytes == 0 {
+			t.Errorf("Measure through the Unix socket isn't consistent")
+		}
+	}
+}
+
+func TestWithUnixSocket(t *testing.T) {
+	testWithUnixSocket(t, "http://localhost/_ping")
+}
+
+func TestWithUnixSocketAndEnvironmentProxy(t *testing.T) {
+	// the proxy of the environment would be used for this host without a Unix socket
+	t.Setenv("HTTP_PROXY", "http://proxy.example.com:3128")
+	testWithUnixSocket(t, "http://docker.example.com/_ping")
+}
+
+func TestMaxBytes(t *testing.T) {
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(make([]byte, 100000))
+		}))
+	defer ts.Close()
+
+	var reports int
+	runtimeConfig := &RuntimeConfig{ProgressCallBack: func(bytes int64, elapsed time.Duration) {
+		reports++
+	}}
+
+	webClient, _ := NewWebClient(&Config{Target: ts.URL, MaxBytes: 1000}, runtimeConfig)
+
+	measure := webClient.DoMeasure(false)
+	if measure.IsFailure || measure.Bytes != 1000 || !measure.Truncated {
+		t.Fatalf("Response body should have been truncated after 1000 bytes, got %d bytes", measure.Bytes)
+	}
+
+	if measure.DownloadRate() <= 0 || measure.WireDownloadRate() < measure.DownloadRate() {
+		t.Errorf("Download rates are inconsistent: %f %f", measure.DownloadRate(), measure.WireDownloadRate())
+	}
+
+	webClient, _ = NewWebClient(&Config{Target: ts.URL, MaxBytes: 100000}, runtimeConfig)
+
+	if measure = webClient.DoMeasure(false); measure.Bytes != 100000 || measure.Truncated {
+		t.Errorf("Response body shouldn't have been truncated, got %d bytes", measure.Bytes)
+	}
+}
+
+func TestProgressReader(t *testing.T) {
+	var lastBytes int64
+	progress := newProgressReader(bytes.NewReader(make([]byte, 1000)), func(bytes int64, elapsed time.Duration) {
+		lastBytes = bytes
+	})
+
+	// reports are limited to one per interval
+	progress.lastReport = time.Now().Add(-progressInterval)
+	_, _ = ioutil.ReadAll(progress)
+
+	if lastBytes == 0 || progress.bytes != 1000 {
+		t.Errorf("Progress should have been reported, got %d bytes", lastBytes)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			if r.ContentLength != int64(len(body)) {
+				http.Error(w, "inconsistent body", http.StatusBadRequest)
+				return
+			}
+			_, _ = fmt.Fprintf(w, "%d", len(body))
+		}))
+	defer ts.Close()
+
+	webClient, _ := NewWebClient(&Config{Target: ts.URL, Method: "POST", UploadSize: 100000}, &RuntimeConfig{})
+
+	measure := webClient.DoMeasure(false)
+	if measure.IsFailure || measure.RequestBytes != 100000 || measure.OutBytes < 100000 {
+		t.Fatalf("Request body should have been uploaded, got %d bytes", measure.RequestBytes)
+	}
+
+	if measure.UploadRate() <= 0 || measure.SentBytes < 100000 {
+		t.Errorf("Upload rate should have been computed")
+	}
+
+	// the body is streamed to the connection with HTTP/1.0 as well
+	webClient, _ = NewWebClient(&Config{Target: ts.URL, Method: "POST", UploadSize: 1 << 20, HTTP10: true}, &RuntimeConfig{})
+
+	if measure = webClient.DoMeasure(false); measure.IsFailure || measure.StatusCode != 200 || measure.Proto != "HTTP/1.0" || measure.SentBytes < 1<<20 {
+		t.Fatalf("Request body should have been uploaded with HTTP/1.0: %s (code=%d)", measure.FailureCause, measure.StatusCode)
+	}
+
+	tlsServer := httptest.NewTLSServer(ts.Config.Handler)
+	defer tlsServer.Close()
+
+	webClient, _ = NewWebClient(&Config{Target: tlsServer.URL, Method: "POST", Data: "hello", NoCheckCertificate: true}, &RuntimeConfig{})
+
+	if measure = webClient.DoMeasure(false); measure.IsFailure || measure.SentBytes == 0 || measure.SentBytes >= measure.OutBytes {
+		t.Errorf("Bytes of the TLS handshake shouldn't be part of the upload, got %d bytes out of %d", measure.SentBytes, measure.OutBytes)
+	}
+
+	file := filepath.Join(t.TempDir(), "body")
+	if err := ioutil.WriteFile(file, make([]byte, 5000), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	webClient, _ = NewWebClient(&Config{Target: ts.URL, Method: "PUT", UploadFile: file}, &RuntimeConfig{})
+
+	for i := 0; i < 2; i++ {
+		if measure = webClient.DoMeasure(false); measure.IsFailure || measure.RequestBytes != 5000 {
+			t.Fatalf("File should have been uploaded, got %d bytes", measure.RequestBytes)
+		}
+	}
+
+	webClient, _ = NewWebClient(&Config{Target: ts.URL, Method: "PUT", UploadFile: file + ".missing"}, &RuntimeConfig{})
+
+	if measure = webClient.DoMeasure(false); !measure.IsFailure {
+		t.Errorf("Upload of a missing file should have failed")
+	}
+}
+
+func TestDoMeasureContext(t *testing.T) {
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}))
+	defer ts.Close()
+
+	webClient, _ := NewWebClient(&Config{Target: ts.URL}, &RuntimeConfig{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if measure := webClient.(ContextWebClient).DoMeasureContext(ctx, false); !measure.IsFailure || time.Since(start) > time.Second {
+		t.Errorf("Request should have been aborted with its context, it lasted %s", time.Since(start))
+	}
+}
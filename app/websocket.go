@@ -0,0 +1,372 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fever.ch/http-ping/stats"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const protoWebSocket = "WebSocket"
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xa
+)
+
+// wsMaxPayload is the largest frame accepted from servers
+const wsMaxPayload = 16 << 20
+
+// wsGUID is used to compute the accept key of the handshake (RFC 6455 Section 1.3)
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsClientImpl measures the round trip of messages on a WebSocket connection, the upgrade of the connection is done
+// and measured by a web client
+type wsClientImpl struct {
+	*webClientImpl
+	target string
+
+	key        string
+	conn       io.ReadWriteCloser
+	reader     *bufio.Reader
+	remoteAddr string
+	upgradeErr error
+}
+
+// NewWebSocketClient builds a new instance of wsClientImpl, the target is a ws:// URL (cleartext) or a wss:// URL
+// (TLS), ping frames are sent unless a message to be echoed by the server is configured
+func NewWebSocketClient(config *Config, runtimeConfig *RuntimeConfig) (WebClient, error) {
+	parsedURL, err := url.Parse(config.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	httpURL := *parsedURL
+	httpURL.Scheme = "http"
+	if targetScheme(config.Target) == "wss" {
+		httpURL.Scheme = "https"
+	}
+
+	// the upgrade mechanism only exists in HTTP/1.1
+	httpConfig := *config
+	httpConfig.Target = httpURL.String()
+	httpConfig.Method = http.MethodGet
+	httpConfig.DisableHTTP2 = true
+	httpConfig.HTTP2PriorKnowledge, httpConfig.H2CUpgrade, httpConfig.HTTP10 = false, false, false
+
+	webClient, err := NewWebClient(&httpConfig, runtimeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	wsClient := &wsClientImpl{webClientImpl: webClient.(*webClientImpl), target: config.Target}
+
+	// the timeout of the HTTP client would also apply to the upgraded connection
+	wsClient.useSetupTimeouts()
+
+	wsClient.prepareRequest = wsClient.prepareUpgrade
+	wsClient.upgrade = wsClient.acceptUpgrade
+
+	return wsClient, nil
+}
+
+func (wsClient *wsClientImpl) URL() string {
+	return wsClient.target
+}
+
+func (wsClient *wsClientImpl) prepareUpgrade(req *http.Request) {
+	key := make([]byte, 16)
+	_, _ = rand.Read(key)
+	wsClient.key = base64.StdEncoding.EncodeToString(key)
+
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", wsClient.key)
+}
+
+func (wsClient *wsClientImpl) acceptUpgrade(res *http.Response, conn io.ReadWriteCloser, measure *HTTPMeasure) {
+	accept := sha1.Sum([]byte(wsClient.key + wsGUID))
+	if !strings.EqualFold(res.Header.Get("Upgrade"), "websocket") || res.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(accept[:]) {
+		wsClient.upgradeErr = errors.New("WebSocket handshake failed (invalid accept key)")
+		_ = conn.Close()
+		return
+	}
+
+	wsClient.conn = conn
+	wsClient.reader = bufio.NewReader(conn)
+	wsClient.remoteAddr = measure.RemoteAddr
+}
+
+// DoMeasure evaluates the round trip of a message on a WebSocket connection, the connection is established first if
+// needed
+func (wsClient *wsClientImpl) DoMeasure(_ bool) *HTTPMeasure {
+	return wsClient.DoMeasureContext(context.Background(), false)
+}
+
+// DoMeasureContext does the same as DoMeasure, the connection is closed if ctx is canceled
+func (wsClient *wsClientImpl) DoMeasureContext(ctx context.Context, _ bool) *HTTPMeasure {
+	var measure *HTTPMeasure
+
+	if wsClient.conn == nil {
+		wsClient.upgradeErr = nil
+		measure = wsClient.webClientImpl.DoMeasureContext(ctx, false)
+
+		if wsClient.upgradeErr != nil {
+			return &HTTPMeasure{IsFailure: true, FailureCause: wsClient.upgradeErr.Error()}
+		}
+		if wsClient.conn == nil {
+			if measure.IsFailure {
+				return measure
+			}
+			return &HTTPMeasure{IsFailure: true, FailureCause: fmt.Sprintf("WebSocket upgrade refused (code=%d)", measure.StatusCode)}
+		}
+		measure.WebSocketUpgrade = measure.TotalTime
+	} else {
+		measure = &HTTPMeasure{
+			SocketReused: true,
+			RemoteAddr:   wsClient.remoteAddr,
+
+			DNSResolution:     stats.MeasureNotInitialized,
+			TCPHandshake:      stats.MeasureNotInitialized,
+			TLSDuration:       stats.MeasureNotInitialized,
+			ConnEstablishment: stats.MeasureNotInitialized,
+			RequestSending:    stats.MeasureNotInitialized,
+			Wait:              stats.MeasureNotInitialized,
+			ResponseIngesting: stats.MeasureNotInitialized,
+
+			StartTLSDuration: stats.MeasureNotInitialized,
+			ProxyConnection:  stats.MeasureNotInitialized,
+			ProxyTLSDuration: stats.MeasureNotInitialized,
+			ProxyTunnel:      stats.MeasureNotInitialized,
+			SOCKSNegotiation: stats.MeasureNotInitialized,
+			WebSocketUpgrade: stats.MeasureNotInitialized,
+		}
+	}
+
+	measure.Proto = protoWebSocket
+	measure.WebSocketMessage = "pong"
+	if wsClient.config.WebSocketMessage != "" {
+		measure.WebSocketMessage = "echo"
+	}
+
+	rtt, size, err := wsClient.roundTrip(ctx)
+	if err != nil {
+		wsClient.close()
+		return &HTTPMeasure{IsFailure: true, FailureCause: err.Error()}
+	}
+
+	measure.TotalTime = rtt
+	measure.Bytes = size
+	measure.InBytes += atomic.SwapInt64(&wsClient.reads, 0)
+	measure.OutBytes += atomic.SwapInt64(&wsClient.writes, 0)
+
+	if wsClient.config.DisableKeepAlive {
+		_ = writeWebSocketFrame(wsClient.conn, wsOpClose, []byte{0x03, 0xe8})
+		wsClient.close()
+	}
+
+	return measure
+}
+
+func (wsClient *wsClientImpl) close() {
+	if wsClient.conn != nil {
+		_ = wsClient.conn.Close()
+		wsClient.conn = nil
+		wsClient.reader = nil
+	}
+}
+
+// roundTrip sends a ping frame (or the message to be echoed) and waits for the matching pong (or any message), it
+// returns the round trip time and the size of the answer
+func (wsClient *wsClientImpl) roundTrip(ctx context.Context) (rtt stats.Measure, size int64, err error) {
+	conn := wsClient.conn
+
+	// the connection is closed on timeouts, the failure is reported like with the other targets instead of the error
+	// of the closed connection
+	var timedOut int32
+	defer func() {
+		if err != nil && atomic.LoadInt32(&timedOut) == 1 {
+			err = errors.New("Timeout")
+		} else if err != nil {
+			err = errors.New(tcpFailureCause(err))
+		}
+	}()
+
+	// the connection is closed as well if the measure is canceled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	// the connection is closed if the answer doesn't come in time
+	if wsClient.config.Wait > 0 {
+		timeout := time.AfterFunc(wsClient.config.Wait, func() {
+			atomic.StoreInt32(&timedOut, 1)
+			_ = conn.Close()
+		})
+		defer timeout.Stop()
+	}
+
+	opcode := byte(wsOpPing)
+	payload := []byte(fmt.Sprintf("http-ping %d", time.Now().UnixNano()))
+	if wsClient.config.WebSocketMessage != "" {
+		opcode = wsOpText
+		payload = []byte(wsClient.config.WebSocketMessage)
+	}
+
+	rttTimer := newTimer()
+	rttTimer.start()
+
+	if err := writeWebSocketFrame(conn, opcode, payload); err != nil {
+		return stats.MeasureNotValid, 0, err
+	}
+
+	var message []byte
+	for {
+		fin, frameOpcode, framePayload, err := readWebSocketFrame(wsClient.reader)
+		if err != nil {
+			return stats.MeasureNotValid, 0, err
+		}
+
+		switch frameOpcode {
+		case wsOpPing:
+			if err := writeWebSocketFrame(conn, wsOpPong, framePayload); err != nil {
+				return stats.MeasureNotValid, 0, err
+			}
+		case wsOpClose:
+			return stats.MeasureNotValid, 0, errors.New("WebSocket connection closed by the server")
+		case wsOpPong:
+			if opcode == wsOpPing && bytes.Equal(framePayload, payload) {
+				rttTimer.stop()
+				return rttTimer.measure(), int64(len(framePayload)), nil
+			}
+		case wsOpText, wsOpBinary, wsOpContinuation:
+			if opcode == wsOpPing {
+				// unsolicited messages are ignored while waiting for the pong
+				continue
+			}
+			message = append(message, framePayload...)
+			if fin {
+				rttTimer.stop()
+				return rttTimer.measure(), int64(len(message)), nil
+			}
+		}
+	}
+}
+
+// writeWebSocketFrame writes a single frame, frames sent by clients are masked
+func writeWebSocketFrame(w io.Writer, opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode, 0x80}
+
+	switch length := len(payload); {
+	case length < 126:
+		frame[1] |= byte(length)
+	case length <= 0xffff:
+		frame[1] |= 126
+		frame = append(frame, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame[1] |= 127
+		frame = append(frame, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+
+	mask := make([]byte, 4)
+	_, _ = rand.Read(mask)
+	frame = append(frame, mask...)
+
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// readWebSocketFrame reads a single frame
+func readWebSocketFrame(r *bufio.Reader) (bool, byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin, opcode, masked := header[0]&0x80 != 0, header[0]&0x0f, header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(r, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(r, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended)
+	}
+
+	if length > wsMaxPayload {
+		return false, 0, nil, fmt.Errorf("WebSocket frame too large (%d bytes)", length)
+	}
+
+	mask := make([]byte, 4)
+	if masked {
+		if _, err := io.ReadFull(r, mask); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return fin, opcode, payload, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"golang.org/x/net/websocket"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newWebSocketTestServer(tls bool) (*httptest.Server, string) {
+	handler := http.NewServeMux()
+	handler.Handle("/echo", websocket.Server{Handler: func(conn *websocket.Conn) {
+		_, _ = io.Copy(conn, conn)
+	}})
+	handler.Handle("/silent", websocket.Server{Handler: func(conn *websocket.Conn) {
+		// pings are answered while reading, this connection is never read
+		time.Sleep(500 * time.Millisecond)
+	}})
+	handler.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("Hello"))
+	})
+
+	if tls {
+		ts := httptest.NewTLSServer(handler)
+		return ts, strings.Replace(ts.URL, "https://", "wss://", 1)
+	}
+	ts := httptest.NewServer(handler)
+	return ts, strings.Replace(ts.URL, "http://", "ws://", 1)
+}
+
+func TestWebSocketClient(t *testing.T) {
+	for _, tls := range []bool{false, true} {
+		ts, target := newWebSocketTestServer(tls)
+
+		for _, message := range []string{"", "Hello"} {
+			wsClient, err := NewWebSocketClient(&Config{Target: target + "/echo", WebSocketMessage: message, NoCheckCertificate: true}, &RuntimeConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if wsClient.URL() != target+"/echo" {
+				t.Errorf("URL of WebSocket client should be its target, got %s", wsClient.URL())
+			}
+
+			for i := 0; i < 3; i++ {
+				measure := wsClient.DoMeasure(false)
+				if measure.IsFailure || measure.Proto != protoWebSocket || !measure.TotalTime.IsValid() {
+					t.Fatalf("WebSocket round trip with %s should have succeed: %s", target, measure.FailureCause)
+				}
+
+				if measure.WebSocketUpgrade.IsValid() != (i == 0) || measure.SocketReused != (i > 0) || measure.TLSDuration.IsValid() != (tls && i == 0) {
+					t.Errorf("WebSocket upgrade should only be measured once")
+				}
+
+				if message != "" && (measure.WebSocketMessage != "echo" || measure.Bytes != int64(len(message))) {
+					t.Errorf("Message should have been echoed by the server")
+				}
+				if message == "" && measure.WebSocketMessage != "pong" {
+					t.Errorf("Pong should have been received")
+				}
+			}
+		}
+
+		wsClient, _ := NewWebSocketClient(&Config{Target: target + "/echo", DisableKeepAlive: true, NoCheckCertificate: true}, &RuntimeConfig{})
+		for i := 0; i < 2; i++ {
+			if measure := wsClient.DoMeasure(false); measure.IsFailure || !measure.WebSocketUpgrade.IsValid() {
+				t.Errorf("WebSocket connection should be established for each measure without keep-alive: %s", measure.FailureCause)
+			}
+		}
+
+		wsClient, _ = NewWebSocketClient(&Config{Target: target + "/plain", NoCheckCertificate: true}, &RuntimeConfig{})
+		if measure := wsClient.DoMeasure(false); !measure.IsFailure || measure.FailureCause != "WebSocket upgrade refused (code=200)" {
+			t.Errorf("WebSocket upgrade should have been refused: %s", measure.FailureCause)
+		}
+
+		wsClient, _ = NewWebSocketClient(&Config{Target: target + "/silent", Wait: 100 * time.Millisecond, NoCheckCertificate: true}, &RuntimeConfig{})
+		if measure := wsClient.DoMeasure(false); !measure.IsFailure || measure.FailureCause != "Timeout" {
+			t.Errorf("WebSocket round trip should have timed out: %s", measure.FailureCause)
+		}
+
+		ts.Close()
+	}
+}
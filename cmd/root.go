@@ -25,7 +25,10 @@ import (
 	"io"
 	"math"
 	"net"
+	"os"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -33,7 +36,16 @@ import (
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	rootCmd := prepareRootCmd(app.NewHTTPPing)
-	cobra.CheckErr(rootCmd.Execute())
+	err := rootCmd.Execute()
+
+	// like ping, the exit code is 1 if the run went wrong (i.e. no answer) and 2 on other errors
+	var exitError *app.ExitError
+	if errors.As(err, &exitError) {
+		os.Exit(exitError.Code)
+	} else if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(2)
+	}
 }
 
 // extraConfig are config items that are triggered by cobra, but which are not part of the config of HTTPPing, these
@@ -44,12 +56,21 @@ type extraConfig struct {
 	head bool
 
 	quiet, verbose bool
+	tui            bool
 
 	cookies []string
 
 	headers []string
 
 	parameters []string
+
+	uploadSize string
+
+	deadline string
+
+	maxLoss string
+	maxP95  time.Duration
+	maxAvg  time.Duration
 }
 
 type runner struct {
@@ -68,6 +89,10 @@ func (runner *runner) run() error {
 		runner.loadLog,
 		runner.loadNetwork,
 		runner.loadDNS,
+		runner.loadSchedule,
+		runner.loadLoad,
+		runner.loadBounds,
+		runner.loadThresholds,
 	}
 
 	for _, loader := range loaders {
@@ -106,7 +131,7 @@ func (runner *runner) loadTarget() error {
 
 	runner.config.Target = runner.args[0]
 
-	if a, e := regexp.MatchString("^https?://", runner.config.Target); e == nil && !a {
+	if a, e := regexp.MatchString("^(https?|tcp|tls|grpcs?|wss?)://", runner.config.Target); e == nil && !a {
 		runner.config.Target = "https://" + runner.config.Target
 	}
 	return nil
@@ -124,11 +149,60 @@ func (runner *runner) loadNetwork() error {
 		runner.config.IPProtocol = "ip"
 	}
 
+	if runner.config.SourceIP != "" && net.ParseIP(runner.config.SourceIP) == nil {
+		return errors.New("source IP should be an IPv4 or IPv6 address")
+	}
+
+	if runner.config.Proxy != "" {
+		if a, e := regexp.MatchString("^https?://[^/]+", runner.config.Proxy); e != nil || !a {
+			return errors.New("proxy should be an http:// or https:// URL (i.e. http://proxy.example.com:3128)")
+		}
+		if runner.config.ConnTarget != "" {
+			return errors.New("proxy and connection target cannot be enforced simultaneously")
+		}
+	} else if runner.config.ProxyUser != "" || runner.config.NoProxy != "" {
+		return errors.New("proxy user and no-proxy list require a proxy")
+	}
+
+	if runner.config.SOCKS5 != "" {
+		if runner.config.Proxy != "" {
+			return errors.New("proxy and SOCKS5 proxy cannot be enforced simultaneously")
+		}
+		if _, _, err := net.SplitHostPort(runner.config.SOCKS5[strings.LastIndex(runner.config.SOCKS5, "@")+1:]); err != nil {
+			return errors.New("SOCKS5 proxy should be in the form [user:password@]host:port")
+		}
+	} else if runner.config.SOCKS5RemoteDNS {
+		return errors.New("remote DNS resolution requires a SOCKS5 proxy")
+	}
+
+	if (runner.config.Proxy != "" || runner.config.SOCKS5 != "") && regexp.MustCompile("^(tcp|tls)://").MatchString(runner.config.Target) {
+		return errors.New("proxies cannot be used with tcp:// and tls:// targets")
+	}
+
+	if runner.config.UnixSocket != "" {
+		if a, e := regexp.MatchString("^https?://", runner.config.Target); e != nil || !a {
+			return errors.New("a Unix socket can only be used with http:// and https:// targets")
+		}
+		if runner.config.ConnTarget != "" || runner.config.Proxy != "" || runner.config.SOCKS5 != "" ||
+			runner.config.SourceIP != "" || runner.config.Interface != "" {
+			return errors.New("a Unix socket cannot be enforced with a connection target, a proxy, a source IP or an interface")
+		}
+	}
+
+	if (runner.config.SourceIP != "" || runner.config.Interface != "") && runner.config.FullDNS {
+		return errors.New("source IP and interface cannot be enforced with full DNS resolution")
+	}
+
 	return nil
 }
 
 func (runner *runner) loadLog() error {
-	if runner.xp.verbose {
+	if runner.xp.tui {
+		if runner.xp.quiet || runner.xp.verbose {
+			return errors.New("the dashboard cannot be combined with quiet or verbose output")
+		}
+		runner.config.LogLevel = 3
+	} else if runner.xp.verbose {
 		if runner.xp.quiet {
 			return errors.New("quiet and verbose cannot be enforced simultaneously")
 		}
@@ -138,6 +212,10 @@ func (runner *runner) loadLog() error {
 	} else {
 		runner.config.LogLevel = 1
 	}
+
+	if runner.config.HistogramPhases {
+		runner.config.Histogram = true
+	}
 	return nil
 }
 
@@ -150,6 +228,116 @@ func (runner *runner) loadDNS() error {
 	if runner.config.DNSServer != "" && net.ParseIP(runner.config.DNSServer) == nil {
 		return errors.New("DNS server should be an IPv4 or IPv6 address")
 	}
+
+	if runner.config.DNSClientSubnet != "" {
+		if runner.config.DNSServer == "" {
+			return errors.New("DNS client subnet can only be used with a specific DNS server")
+		}
+		if _, _, err := net.ParseCIDR(runner.config.DNSClientSubnet); err != nil {
+			return errors.New("DNS client subnet should be an IPv4 or IPv6 network in CIDR notation (i.e. 203.0.113.0/24)")
+		}
+	}
+	return nil
+}
+
+func (runner *runner) loadSchedule() error {
+	if runner.config.PoissonIntervals && runner.config.Jitter > 0 {
+		return errors.New("jitter and Poisson intervals cannot be enforced simultaneously")
+	}
+
+	if runner.config.Jitter > runner.config.Interval {
+		return errors.New("jitter cannot be larger than the interval")
+	}
+
+	// the schedule of a fixed rate would never wait otherwise
+	if runner.config.FixedRate && runner.config.Interval <= 0 {
+		return errors.New("the interval should be positive with a fixed rate")
+	}
+
+	if runner.isFlagUsed("max-in-flight") {
+		if !runner.config.FixedRate {
+			return errors.New("the maximal number of requests in flight can only be set with a fixed rate")
+		}
+		if runner.config.MaxInFlight < 1 {
+			return errors.New("the maximal number of requests in flight should be at least 1")
+		}
+	}
+	return nil
+}
+
+// minLoadRate and maxLoadRate are the bounds of the rate of the load mode (one request every 1000 seconds, one request
+// every microsecond)
+const (
+	minLoadRate = 0.001
+	maxLoadRate = 1e6
+)
+
+func (runner *runner) loadLoad() error {
+	if !runner.isFlagUsed("load-rate") {
+		if runner.isFlagUsed("load-clients") || runner.isFlagUsed("load-window") {
+			return errors.New("load clients and window can only be set in load mode (with a load rate)")
+		}
+		return nil
+	}
+
+	// the interval between requests would overflow below this rate, and would be rounded down to nothing above
+	if runner.config.LoadRate < minLoadRate || runner.config.LoadRate > maxLoadRate {
+		return fmt.Errorf("the load rate should be between %g and %g requests/s", minLoadRate, maxLoadRate)
+	}
+	if runner.config.LoadClients < 1 {
+		return errors.New("the load mode needs at least 1 client")
+	}
+	if runner.config.LoadWindow <= 0 {
+		return errors.New("the load window should be positive")
+	}
+	if runner.isFlagUsed("interval") || runner.config.FixedRate {
+		return errors.New("the interval and the fixed rate are defined by the load rate in load mode")
+	}
+	if runner.config.LogLevel > 1 {
+		return errors.New("the load mode cannot be combined with verbose output or the dashboard")
+	}
+	return nil
+}
+
+func (runner *runner) loadBounds() error {
+	if runner.config.UntilSuccess && runner.config.UntilFailure {
+		return errors.New("until success and until failure cannot be enforced simultaneously")
+	}
+
+	if runner.config.Duration < 0 {
+		return fmt.Errorf("invalid duration `%s'", runner.config.Duration)
+	}
+
+	if runner.xp.deadline != "" {
+		now := time.Now()
+		deadline, err := parseDeadline(runner.xp.deadline, now)
+		if err != nil {
+			return err
+		}
+		if !deadline.After(now) {
+			return fmt.Errorf("deadline `%s' is already over", runner.xp.deadline)
+		}
+		runner.config.Deadline = deadline
+	}
+	return nil
+}
+
+func (runner *runner) loadThresholds() error {
+	if runner.isFlagUsed("max-loss") {
+		maxLoss, err := strconv.ParseFloat(strings.TrimSuffix(runner.xp.maxLoss, "%"), 64)
+		if err != nil || maxLoss < 0 || maxLoss > 100 {
+			return fmt.Errorf("invalid maximal loss `%s', it should be a percentage (i.e. 5%%)", runner.xp.maxLoss)
+		}
+		runner.config.Thresholds = append(runner.config.Thresholds, app.Threshold{Statistic: "loss", Max: maxLoss})
+	}
+
+	if runner.isFlagUsed("max-p95") {
+		runner.config.Thresholds = append(runner.config.Thresholds, app.Threshold{Statistic: "p95", Max: float64(runner.xp.maxP95) / float64(time.Millisecond)})
+	}
+
+	if runner.isFlagUsed("max-avg") {
+		runner.config.Thresholds = append(runner.config.Thresholds, app.Threshold{Statistic: "avg", Max: float64(runner.xp.maxAvg) / float64(time.Millisecond)})
+	}
 	return nil
 }
 
@@ -163,6 +351,96 @@ func (runner *runner) loadRest() error {
 		runner.config.Method = "HEAD"
 	}
 
+	if runner.config.StartTLS != "" {
+		if !strings.HasPrefix(runner.config.Target, "tls://") {
+			return errors.New("STARTTLS can only be used with a tls:// target")
+		}
+
+		supported := false
+		for _, protocol := range app.StartTLSProtocols() {
+			supported = supported || protocol == runner.config.StartTLS
+		}
+		if !supported {
+			return fmt.Errorf("unsupported STARTTLS protocol `%s', it should be one of: %s", runner.config.StartTLS, strings.Join(app.StartTLSProtocols(), ", "))
+		}
+	}
+
+	if len(runner.config.ALPN) > 0 && !strings.HasPrefix(runner.config.Target, "tls://") {
+		return errors.New("ALPN protocols can only be offered to a tls:// target")
+	}
+
+	protocols := 0
+	for _, enforced := range []bool{runner.config.DisableHTTP2, runner.config.HTTP2PriorKnowledge, runner.config.H2CUpgrade, runner.config.HTTP10} {
+		if enforced {
+			protocols++
+		}
+	}
+	if protocols > 1 {
+		return errors.New("disable-http2, http2-prior-knowledge, h2c and http1.0 cannot be enforced simultaneously")
+	}
+	if protocols > 0 && runner.config.Proxy != "" && !runner.config.DisableHTTP2 {
+		return errors.New("HTTP protocol cannot be enforced through an HTTP proxy")
+	}
+
+	if runner.config.GRPCService != "" {
+		if a, e := regexp.MatchString("^grpcs?://", runner.config.Target); e != nil || !a {
+			return errors.New("gRPC service can only be used with grpc:// and grpcs:// targets")
+		}
+	}
+
+	if runner.config.WebSocketMessage != "" {
+		if a, e := regexp.MatchString("^wss?://", runner.config.Target); e != nil || !a {
+			return errors.New("WebSocket message can only be used with ws:// and wss:// targets")
+		}
+	}
+
+	if runner.xp.uploadSize != "" {
+		size, err := parseSize(runner.xp.uploadSize)
+		if err != nil {
+			return err
+		}
+		runner.config.UploadSize = size
+	}
+
+	bodies := 0
+	for _, defined := range []bool{runner.config.Data != "", runner.config.UploadSize > 0, runner.config.UploadFile != ""} {
+		if defined {
+			bodies++
+		}
+	}
+	if bodies > 1 {
+		return errors.New("data, upload size and upload file cannot be enforced simultaneously")
+	}
+	if bodies > 0 {
+		if a, e := regexp.MatchString("^https?://", runner.config.Target); e != nil || !a {
+			return errors.New("request bodies can only be sent to http:// and https:// targets")
+		}
+		if runner.xp.head {
+			return errors.New("HEAD requests cannot have a body")
+		}
+		if !runner.isFlagUsed("method") {
+			runner.config.Method = "POST"
+		}
+	}
+
+	if runner.config.MaxBytes < 0 {
+		return fmt.Errorf("invalid maximal count of bytes to be read `%d'", runner.config.MaxBytes)
+	}
+
+	if runner.config.Stream {
+		if a, e := regexp.MatchString("^https?://", runner.config.Target); e != nil || !a {
+			return errors.New("streams can only be read from http:// and https:// targets")
+		}
+		if runner.config.StreamFirstBytes > 0 && runner.config.StreamFirstLines > 0 {
+			return errors.New("first bytes and first lines of streams cannot be enforced simultaneously")
+		}
+		if runner.config.HTTP10 || runner.config.HTTP2PriorKnowledge || runner.config.H2CUpgrade {
+			return errors.New("streams cannot be read with http1.0, http2-prior-knowledge or h2c")
+		}
+	} else if runner.isFlagUsed("stream-first-bytes") || runner.isFlagUsed("stream-first-lines") || runner.isFlagUsed("stream-duration") {
+		return errors.New("stream options require the stream mode")
+	}
+
 	if runner.config.Count <= 0 {
 		return fmt.Errorf("invalid count of requests to be sent `%d'", runner.config.Count)
 	}
@@ -196,6 +474,50 @@ func (runner *runner) loadRest() error {
 	return nil
 }
 
+// parseSize parses a count of bytes, with an optional unit (i.e. 100, 512kB, 10MB, 1GB or 1MiB)
+func parseSize(size string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+		{"kB", 1000}, {"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000},
+		{"B", 1}, {"", 1},
+	}
+
+	for _, unit := range units {
+		if strings.HasSuffix(size, unit.suffix) {
+			value, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(size, unit.suffix)), 64)
+			if err != nil || value <= 0 {
+				break
+			}
+			return int64(value * float64(unit.multiplier)), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid size `%s' (i.e. 100, 512kB, 10MB or 1MiB)", size)
+}
+
+// parseDeadline parses a deadline which is either relative (a duration, i.e. 10m), absolute (RFC 3339) or a time of
+// the day (i.e. 18:30, the next one if it's already past)
+func parseDeadline(deadline string, now time.Time) (time.Time, error) {
+	if duration, err := time.ParseDuration(deadline); err == nil {
+		return now.Add(duration), nil
+	}
+	if t, err := time.Parse(time.RFC3339, deadline); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.ParseInLocation(layout, deadline, now.Location()); err == nil {
+			t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+			if !t.After(now) {
+				t = t.AddDate(0, 0, 1)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid deadline `%s', it should be a duration (i.e. 10m), a date (RFC 3339) or a time (i.e. 18:30)", deadline)
+}
+
 func splitPair(str string) (string, string, error) {
 	r := regexp.MustCompile("^([[:alnum:]]+)=(.*)$")
 	e := r.FindStringSubmatch(str)
@@ -226,7 +548,23 @@ func prepareRootCmd(appLogic func(config *app.Config, stdout io.Writer) (app.HTT
 		Use: "http-ping [flags] target-URL",
 
 		Short: "An utility which evaluates the latency of HTTP/S requests",
-		Long:  `An utility which evaluates the latency of HTTP/S requests`,
+		Long: `An utility which evaluates the latency of HTTP/S requests
+
+The target can also be:
+  - a tcp://host:port URL, in this case only TCP connections are established (no HTTP exchange)
+  - a tls://host:port URL, in this case only TCP connections and TLS handshakes are done (no HTTP exchange),
+    STARTTLS can be used for services which upgrade their connections to TLS (i.e. SMTP on port 25 or 587)
+  - a grpc://host:port URL (cleartext) or a grpcs://host:port URL (TLS), in this case the standard health check
+    of gRPC (grpc.health.v1.Health/Check) is called
+  - a ws:// URL (cleartext) or a wss:// URL (TLS), in this case a WebSocket connection is established and the round
+    trip of ping frames (or of messages echoed by the server) is measured
+
+With --load-rate, http-ping generates a constant load: requests are started at the given rate whatever their latency
+is (open model) by a bounded pool of clients, the achieved rate, the errors and the latency percentiles are printed
+for each window of time (--load-window), and the errors by cause at the end.
+
+The exit code is 1 if no answer is received or if a threshold (--max-loss, --max-p95, --max-avg) is exceeded,
+and 2 on other errors.`,
 
 		Version: app.Version,
 		RunE:    runAndError(config, xp, appLogic),
@@ -250,14 +588,54 @@ func prepareRootCmd(appLogic func(config *app.Config, stdout io.Writer) (app.HTT
 
 	rootCmd.Flags().DurationVarP(&config.Interval, "interval", "i", 1*time.Second, "define the wait time between each request")
 
+	rootCmd.Flags().DurationVar(&config.Jitter, "jitter", 0, "add a random variation (up to this duration) to each interval")
+
+	rootCmd.Flags().BoolVar(&config.PoissonIntervals, "poisson", false, "draw the intervals from an exponential distribution (Poisson process) whose mean is the interval")
+
+	rootCmd.Flags().BoolVar(&config.FixedRate, "fixed-rate", false, "start a request every interval, without waiting for the completion of the previous one")
+
+	rootCmd.Flags().IntVar(&config.MaxInFlight, "max-in-flight", 1, "with a fixed rate, maximal number of requests in flight (each on its own connection), requests are skipped beyond")
+
+	rootCmd.Flags().BoolVar(&config.CorrectOmission, "corrected-percentiles", false, "report the percentiles corrected for coordinated omission (the requests which couldn't be sent on schedule) next to the measured ones")
+
+	rootCmd.Flags().Float64Var(&config.LoadRate, "load-rate", 0, "enable the load mode, requests are started at this rate (per second) whatever their latency is")
+
+	rootCmd.Flags().IntVar(&config.LoadClients, "load-clients", 10, "in load mode, maximal number of requests in flight (each client has its own connection), requests are skipped beyond")
+
+	rootCmd.Flags().DurationVar(&config.LoadWindow, "load-window", time.Second, "in load mode, duration of the windows of time whose rate, errors and percentiles are printed")
+
 	rootCmd.Flags().Int64VarP(&config.Count, "count", "c", math.MaxInt, "define the number of request to be sent")
 
 	rootCmd.Flag("count").DefValue = "unlimited"
 
+	rootCmd.Flags().DurationVar(&config.Duration, "duration", 0, "stop after this duration (i.e. 10m)")
+
+	rootCmd.Flags().StringVar(&xp.deadline, "deadline", "", "stop at this deadline, either relative (i.e. 10m), a date (RFC 3339) or a time of the day (i.e. 18:30)")
+
+	rootCmd.Flags().BoolVar(&config.UntilSuccess, "until-success", false, "stop as soon as a request succeeds (i.e. to wait until a target is up)")
+
+	rootCmd.Flags().BoolVar(&config.UntilFailure, "until-failure", false, "stop as soon as a request fails (i.e. to wait until a target is down)")
+
 	rootCmd.Flags().BoolVarP(&xp.verbose, "verbose", "v", false, "print more details")
 
 	rootCmd.Flags().BoolVarP(&xp.quiet, "quiet", "q", false, "print less details")
 
+	rootCmd.Flags().BoolVar(&xp.tui, "tui", false, "show a live dashboard with rolling statistics instead of one line per request")
+
+	rootCmd.Flags().StringVar(&config.JSONL, "jsonl", "", "write the measures and the statistics to a file as JSON objects, one per line")
+
+	rootCmd.Flags().BoolVar(&config.Histogram, "histogram", false, "print a histogram and a heatmap over time of the latencies at the end")
+
+	rootCmd.Flags().BoolVar(&config.HistogramPhases, "histogram-phases", false, "print the histogram of each phase of the requests as well (implies --histogram)")
+
+	rootCmd.Flags().StringVar(&xp.maxLoss, "max-loss", "", "fail (exit code 1) if the loss rate exceeds this percentage (i.e. 5%)")
+
+	rootCmd.Flags().DurationVar(&xp.maxP95, "max-p95", 0, "fail (exit code 1) if the 95th percentile of the latency exceeds this duration")
+
+	rootCmd.Flags().DurationVar(&xp.maxAvg, "max-avg", 0, "fail (exit code 1) if the average latency exceeds this duration")
+
+	rootCmd.Flags().DurationVar(&config.SummaryInterval, "summary-interval", 0, "print the interim statistics periodically (they're printed on SIGQUIT and SIGUSR1 as well)")
+
 	rootCmd.Flags().BoolVarP(&config.NoCheckCertificate, "insecure", "k", false, "allow insecure server connections when using SSL")
 
 	rootCmd.Flags().StringArrayVarP(&xp.cookies, "cookie", "", []string{}, "add one or more cookies, in the form name=value")
@@ -282,12 +660,64 @@ func prepareRootCmd(appLogic func(config *app.Config, stdout io.Writer) (app.HTT
 
 	rootCmd.Flags().BoolVarP(&config.DisableHTTP2, "disable-http2", "", false, "disable the HTTP/2 protocol")
 
+	rootCmd.Flags().BoolVarP(&config.HTTP2PriorKnowledge, "http2-prior-knowledge", "", false, "use HTTP/2 without upgrade on cleartext connections (http:// targets)")
+
+	rootCmd.Flags().BoolVarP(&config.H2CUpgrade, "h2c", "", false, "upgrade cleartext connections to HTTP/2 (http:// targets), connections are not reused")
+
+	rootCmd.Flags().BoolVarP(&config.HTTP10, "http1.0", "", false, "use HTTP/1.0 requests, connections are closed after each request")
+
+	rootCmd.Flags().StringVarP(&config.Proxy, "proxy", "", "", "use a specific HTTP/S proxy (i.e. http://proxy.example.com:3128), by default the proxy is defined by the environment (HTTP_PROXY, HTTPS_PROXY, NO_PROXY)")
+
+	rootCmd.Flags().StringVarP(&config.ProxyUser, "proxy-user", "", "", "proxy authentication, in the form user:password")
+
+	rootCmd.Flags().StringVarP(&config.NoProxy, "noproxy", "", "", "comma-separated list of hosts which are not reached through the proxy (i.e. localhost,.example.com,10.0.0.0/8)")
+
+	rootCmd.Flags().StringVarP(&config.SOCKS5, "socks5", "", "", "use a SOCKS5 proxy, in the form [user:password@]host:port")
+
+	rootCmd.Flags().BoolVarP(&config.SOCKS5RemoteDNS, "socks5-remote-dns", "", false, "let the SOCKS5 proxy resolve the target host")
+
+	rootCmd.Flags().StringVarP(&config.GRPCService, "grpc-service", "", "", "name of the service checked on a grpc:// or grpcs:// target (the whole server by default)")
+
+	rootCmd.Flags().StringVarP(&config.WebSocketMessage, "ws-message", "", "", "message to be echoed by the server of a ws:// or wss:// target (ping frames are sent by default)")
+
+	rootCmd.Flags().StringVarP(&config.Data, "data", "", "", "send a body with the requests (POST is used unless another method is set)")
+
+	rootCmd.Flags().StringVarP(&xp.uploadSize, "upload-size", "", "", "send a body of random bytes of the given size with the requests, i.e. 10MB (POST is used unless another method is set)")
+
+	rootCmd.Flags().StringVarP(&config.UploadFile, "upload-file", "", "", "send the content of a file with the requests (POST is used unless another method is set)")
+
+	rootCmd.Flags().BoolVarP(&config.Throughput, "throughput", "", false, "report the download (and upload) rates of the requests")
+
+	rootCmd.Flags().BoolVarP(&config.Progress, "progress", "", false, "display the progress of large downloads")
+
+	rootCmd.Flags().Int64VarP(&config.MaxBytes, "max-bytes", "", 0, "stop reading the response bodies after N bytes (unlimited by default)")
+
+	rootCmd.Flags().BoolVarP(&config.Stream, "stream", "", false, "measure streamed responses, i.e. time to first Server-Sent Event and gaps between events")
+
+	rootCmd.Flags().Int64VarP(&config.StreamFirstBytes, "stream-first-bytes", "", 0, "in stream mode, consider the first event received with the first N bytes (instead of SSE events)")
+
+	rootCmd.Flags().Int64VarP(&config.StreamFirstLines, "stream-first-lines", "", 0, "in stream mode, consider the first event received with the first N lines (instead of SSE events)")
+
+	rootCmd.Flags().DurationVarP(&config.StreamDuration, "stream-duration", "", 10*time.Second, "in stream mode, maximal time spent reading a stream")
+
+	rootCmd.Flags().StringVarP(&config.UnixSocket, "unix-socket", "", "", "connect to the target through a Unix domain socket (i.e. /var/run/docker.sock)")
+
+	rootCmd.Flags().StringVarP(&config.SourceIP, "source-ip", "", "", "bind connections to a specific source IP address")
+
+	rootCmd.Flags().StringVarP(&config.Interface, "interface", "", "", "bind connections to a specific network interface (i.e. eth1), only on Linux")
+
 	rootCmd.Flags().BoolVarP(&config.FullDNS, "dns-full-resolution", "D", false, "enable full DNS resolution from the root servers")
 
 	rootCmd.Flags().StringVarP(&config.DNSServer, "dns-server", "d", "", "specify an alternate DNS server for resolutions")
 
+	rootCmd.Flags().StringVarP(&config.DNSClientSubnet, "dns-client-subnet", "", "", "add an EDNS client subnet to DNS queries sent to the DNS server (i.e. 203.0.113.0/24)")
+
 	rootCmd.Flags().BoolVarP(&config.CacheDNSRequests, "dns-cache", "", false, "cache DNS requests")
 
+	rootCmd.Flags().StringVarP(&config.StartTLS, "starttls", "", "", fmt.Sprintf("upgrade connections of a tls:// target to TLS with STARTTLS (%s)", strings.Join(app.StartTLSProtocols(), ", ")))
+
+	rootCmd.Flags().StringSliceVarP(&config.ALPN, "alpn", "", []string{}, "offer these protocols with ALPN in TLS handshakes of a tls:// target (i.e. h2,http/1.1), none by default")
+
 	rootCmd.Flags().BoolVarP(&config.KeepCookies, "keep-cookies", "", false, "keep received cookies between requests")
 
 	rootCmd.Flags().BoolVarP(&config.FollowRedirects, "follow-redirects", "F", false, "follow HTTP redirects (codes 3xx)")
@@ -22,6 +22,7 @@ import (
 	"io"
 	"io/ioutil"
 	"testing"
+	"time"
 )
 
 type httpPingMockBuilder struct {
@@ -107,3 +108,363 @@ func TestCookie(t *testing.T) {
 		t.Fatal("cookie flag not taken in account")
 	}
 }
+
+func TestDNSClientSubnet(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--dns-server", "8.8.8.8", "--dns-client-subnet", "203.0.113.0/24", "www.google.com"})
+	if err != nil || config.DNSClientSubnet != "203.0.113.0/24" {
+		t.Fatal("DNS client subnet parameter not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--dns-client-subnet", "203.0.113.0/24", "www.google.com"}); err == nil {
+		t.Fatal("DNS client subnet should require a DNS server")
+	}
+
+	if _, _, err := commandTest(t, []string{"--dns-server", "8.8.8.8", "--dns-client-subnet", "203.0.113.0", "www.google.com"}); err == nil {
+		t.Fatal("DNS client subnet should be in CIDR notation")
+	}
+}
+
+func TestSourceIP(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--source-ip", "127.0.0.1", "--interface", "lo", "www.google.com"})
+	if err != nil || config.SourceIP != "127.0.0.1" || config.Interface != "lo" {
+		t.Fatal("source IP and interface parameters not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--source-ip", "localhost", "www.google.com"}); err == nil {
+		t.Fatal("source IP should be an IP address")
+	}
+}
+
+func TestTCPTarget(t *testing.T) {
+	config, _, err := commandTest(t, []string{"tcp://www.google.com:443"})
+	if err != nil || config.Target != "tcp://www.google.com:443" {
+		t.Fatal("TCP target not taken in account")
+	}
+}
+
+func TestTLSTarget(t *testing.T) {
+	config, _, err := commandTest(t, []string{"tls://www.google.com:443"})
+	if err != nil || config.Target != "tls://www.google.com:443" {
+		t.Fatal("TLS target not taken in account")
+	}
+}
+
+func TestStartTLS(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--starttls", "smtp", "tls://smtp.gmail.com:587"})
+	if err != nil || config.StartTLS != "smtp" {
+		t.Fatal("STARTTLS parameter not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--starttls", "smtp", "www.google.com"}); err == nil {
+		t.Fatal("STARTTLS should require a tls:// target")
+	}
+
+	if _, _, err := commandTest(t, []string{"--starttls", "gopher", "tls://smtp.gmail.com:587"}); err == nil {
+		t.Fatal("unknown STARTTLS protocol should be rejected")
+	}
+}
+
+func TestALPN(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--alpn", "h2,http/1.1", "tls://www.google.com:443"})
+	if err != nil || len(config.ALPN) != 2 || config.ALPN[0] != "h2" || config.ALPN[1] != "http/1.1" {
+		t.Fatal("ALPN parameter not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--alpn", "h2", "www.google.com"}); err == nil {
+		t.Fatal("ALPN should require a tls:// target")
+	}
+}
+
+func TestProxy(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--proxy", "http://proxy.example.com:3128", "--proxy-user", "user:password", "--noproxy", ".example.com", "www.google.com"})
+	if err != nil || config.Proxy != "http://proxy.example.com:3128" || config.ProxyUser != "user:password" || config.NoProxy != ".example.com" {
+		t.Fatal("proxy parameters not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--proxy", "proxy.example.com:3128", "www.google.com"}); err == nil {
+		t.Fatal("proxy should be an URL")
+	}
+
+	if _, _, err := commandTest(t, []string{"--proxy-user", "user:password", "www.google.com"}); err == nil {
+		t.Fatal("proxy user should require a proxy")
+	}
+}
+
+func TestSOCKS5(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--socks5", "user:password@localhost:1080", "--socks5-remote-dns", "www.google.com"})
+	if err != nil || config.SOCKS5 != "user:password@localhost:1080" || !config.SOCKS5RemoteDNS {
+		t.Fatal("SOCKS5 parameters not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--socks5", "localhost", "www.google.com"}); err == nil {
+		t.Fatal("SOCKS5 proxy should require a port")
+	}
+
+	if _, _, err := commandTest(t, []string{"--socks5", "localhost:1080", "--proxy", "http://localhost:3128", "www.google.com"}); err == nil {
+		t.Fatal("SOCKS5 and HTTP proxies should be exclusive")
+	}
+
+	for _, proxy := range [][]string{{"--socks5", "localhost:1080"}, {"--proxy", "http://localhost:3128"}} {
+		for _, target := range []string{"tcp://www.google.com:443", "tls://www.google.com:443"} {
+			if _, _, err := commandTest(t, append(proxy, target)); err == nil {
+				t.Fatalf("%s should be refused with %s", proxy[0], target)
+			}
+		}
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--unix-socket", "/var/run/docker.sock", "http://localhost/_ping"})
+	if err != nil || config.UnixSocket != "/var/run/docker.sock" {
+		t.Fatal("Unix socket not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--unix-socket", "/var/run/docker.sock", "tcp://localhost:80"}); err == nil {
+		t.Fatal("Unix socket should only be accepted with HTTP targets")
+	}
+
+	if _, _, err := commandTest(t, []string{"--unix-socket", "/var/run/docker.sock", "--conn-target", "127.0.0.1:80", "http://localhost/_ping"}); err == nil {
+		t.Fatal("Unix socket and connection target should be exclusive")
+	}
+}
+
+func TestHTTPProtocols(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--http2-prior-knowledge", "http://localhost:8080"})
+	if err != nil || !config.HTTP2PriorKnowledge {
+		t.Fatal("HTTP/2 prior knowledge not taken in account")
+	}
+
+	config, _, err = commandTest(t, []string{"--h2c", "http://localhost:8080"})
+	if err != nil || !config.H2CUpgrade {
+		t.Fatal("h2c upgrade not taken in account")
+	}
+
+	config, _, err = commandTest(t, []string{"--http1.0", "www.google.com"})
+	if err != nil || !config.HTTP10 {
+		t.Fatal("HTTP/1.0 not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--http1.0", "--disable-http2", "www.google.com"}); err == nil {
+		t.Fatal("HTTP protocol options should be exclusive")
+	}
+
+	if _, _, err := commandTest(t, []string{"--h2c", "--proxy", "http://localhost:3128", "http://localhost:8080"}); err == nil {
+		t.Fatal("h2c upgrade through an HTTP proxy should be refused")
+	}
+}
+
+func TestGRPCTarget(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--grpc-service", "backend", "grpcs://localhost:50051"})
+	if err != nil || config.Target != "grpcs://localhost:50051" || config.GRPCService != "backend" {
+		t.Fatal("gRPC target not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--grpc-service", "backend", "www.google.com"}); err == nil {
+		t.Fatal("gRPC service should only be accepted with gRPC targets")
+	}
+}
+
+func TestWebSocketTarget(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--ws-message", "hello", "wss://localhost/echo"})
+	if err != nil || config.Target != "wss://localhost/echo" || config.WebSocketMessage != "hello" {
+		t.Fatal("WebSocket target not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--ws-message", "hello", "www.google.com"}); err == nil {
+		t.Fatal("WebSocket message should only be accepted with WebSocket targets")
+	}
+}
+
+func TestStream(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--stream", "--stream-first-lines", "2", "--stream-duration", "3s", "www.google.com"})
+	if err != nil || !config.Stream || config.StreamFirstLines != 2 || config.StreamDuration != 3*time.Second {
+		t.Fatal("stream parameters not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--stream-duration", "3s", "www.google.com"}); err == nil {
+		t.Fatal("stream options should require the stream mode")
+	}
+
+	for _, protocol := range []string{"--http1.0", "--http2-prior-knowledge", "--h2c"} {
+		if _, _, err := commandTest(t, []string{"--stream", protocol, "http://www.google.com"}); err == nil {
+			t.Fatalf("stream mode should be refused with %s", protocol)
+		}
+	}
+
+	if _, _, err := commandTest(t, []string{"--stream", "--stream-first-lines", "2", "--stream-first-bytes", "10", "www.google.com"}); err == nil {
+		t.Fatal("first lines and first bytes should be exclusive")
+	}
+}
+
+func TestThroughput(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--throughput", "--progress", "--max-bytes", "1000000", "www.google.com"})
+	if err != nil || !config.Throughput || !config.Progress || config.MaxBytes != 1000000 {
+		t.Fatal("throughput parameters not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--max-bytes", "-1", "www.google.com"}); err == nil {
+		t.Fatal("negative maximal count of bytes should be refused")
+	}
+}
+
+func TestUpload(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--upload-size", "10MB", "www.google.com"})
+	if err != nil || config.UploadSize != 10000000 || config.Method != "POST" {
+		t.Fatal("upload size not taken in account")
+	}
+
+	config, _, err = commandTest(t, []string{"--data", "hello", "--method", "PUT", "www.google.com"})
+	if err != nil || config.Data != "hello" || config.Method != "PUT" {
+		t.Fatal("data not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--data", "hello", "--upload-file", "/etc/hosts", "www.google.com"}); err == nil {
+		t.Fatal("data and upload file should be exclusive")
+	}
+
+	if _, _, err := commandTest(t, []string{"--upload-size", "10XB", "www.google.com"}); err == nil {
+		t.Fatal("invalid upload size should be refused")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	sizes := map[string]int64{"100": 100, "100B": 100, "512kB": 512000, "1.5MB": 1500000, "1MiB": 1 << 20, "2GB": 2000000000}
+
+	for size, want := range sizes {
+		if got, err := parseSize(size); err != nil || got != want {
+			t.Errorf("size %s should be %d bytes, got %d", size, want, got)
+		}
+	}
+}
+
+func TestTUI(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--tui", "www.google.com"})
+	if err != nil || config.LogLevel != 3 {
+		t.Fatal("dashboard not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--tui", "-q", "www.google.com"}); err == nil {
+		t.Fatal("dashboard and quiet output should be exclusive")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--histogram-phases", "www.google.com"})
+	if err != nil || !config.Histogram || !config.HistogramPhases {
+		t.Fatal("histogram not taken in account")
+	}
+}
+
+func TestSummaryInterval(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--summary-interval", "1m", "www.google.com"})
+	if err != nil || config.SummaryInterval != time.Minute {
+		t.Fatal("summary interval not taken in account")
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--max-loss", "5%", "--max-p95", "300ms", "--max-avg", "1s", "www.google.com"})
+	want := []app.Threshold{{Statistic: "loss", Max: 5}, {Statistic: "p95", Max: 300}, {Statistic: "avg", Max: 1000}}
+	if err != nil || len(config.Thresholds) != len(want) {
+		t.Fatal("thresholds not taken in account")
+	}
+	for i := range want {
+		if config.Thresholds[i] != want[i] {
+			t.Errorf("threshold %d was incorrect, got: %v, want: %v", i, config.Thresholds[i], want[i])
+		}
+	}
+
+	if _, _, err := commandTest(t, []string{"--max-loss", "150%", "www.google.com"}); err == nil {
+		t.Fatal("invalid maximal loss should be refused")
+	}
+}
+
+func TestBounds(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--duration", "10m", "--deadline", "1h", "--until-success", "www.google.com"})
+	if err != nil || config.Duration != 10*time.Minute || time.Until(config.Deadline) < 59*time.Minute || !config.UntilSuccess {
+		t.Fatal("bounds not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--until-success", "--until-failure", "www.google.com"}); err == nil {
+		t.Fatal("until success and until failure should be exclusive")
+	}
+
+	if _, _, err := commandTest(t, []string{"--duration", "-10m", "www.google.com"}); err == nil {
+		t.Fatal("negative duration should be refused")
+	}
+
+	for _, deadline := range []string{"2021-06-02T08:00:00Z", "-1h"} {
+		if _, _, err := commandTest(t, []string{"--deadline", deadline, "www.google.com"}); err == nil {
+			t.Fatalf("deadline %s is over and should be refused", deadline)
+		}
+	}
+}
+
+func TestParseDeadline(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	deadlines := map[string]time.Time{
+		"90s":                  now.Add(90 * time.Second),
+		"2021-06-02T08:00:00Z": time.Date(2021, 6, 2, 8, 0, 0, 0, time.UTC),
+		"18:30":                time.Date(2021, 6, 1, 18, 30, 0, 0, time.UTC),
+		"08:15:30":             time.Date(2021, 6, 2, 8, 15, 30, 0, time.UTC),
+	}
+
+	for deadline, want := range deadlines {
+		if got, err := parseDeadline(deadline, now); err != nil || !got.Equal(want) {
+			t.Errorf("deadline %s should be %s, got %s", deadline, want, got)
+		}
+	}
+
+	if _, err := parseDeadline("tomorrow", now); err == nil {
+		t.Error("invalid deadline should be refused")
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--jsonl", "measures.jsonl", "www.google.com"})
+	if err != nil || config.JSONL != "measures.jsonl" {
+		t.Fatal("JSON lines output not taken in account")
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--fixed-rate", "--max-in-flight", "4", "--jitter", "100ms", "www.google.com"})
+	if err != nil || !config.FixedRate || config.MaxInFlight != 4 || config.Jitter != 100*time.Millisecond {
+		t.Fatal("schedule not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--poisson", "--jitter", "100ms", "www.google.com"}); err == nil {
+		t.Fatal("jitter and Poisson intervals should be exclusive")
+	}
+
+	if _, _, err := commandTest(t, []string{"--max-in-flight", "4", "www.google.com"}); err == nil {
+		t.Fatal("requests in flight should only be set with a fixed rate")
+	}
+
+	for _, interval := range []string{"0", "-1s"} {
+		if _, _, err := commandTest(t, []string{"--fixed-rate", "--interval", interval, "www.google.com"}); err == nil {
+			t.Fatalf("interval %s should be refused with a fixed rate", interval)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--load-rate", "250", "--load-clients", "20", "www.google.com"})
+	if err != nil || config.LoadRate != 250 || config.LoadClients != 20 || config.LoadWindow != time.Second {
+		t.Fatal("load mode not taken in account")
+	}
+
+	if _, _, err := commandTest(t, []string{"--load-rate", "250", "--interval", "1s", "www.google.com"}); err == nil {
+		t.Fatal("the interval should be refused in load mode")
+	}
+
+	if _, _, err := commandTest(t, []string{"--load-clients", "20", "www.google.com"}); err == nil {
+		t.Fatal("load clients should only be set in load mode")
+	}
+
+	for _, rate := range []string{"0", "-5", "1e-12", "2e6", "1e300"} {
+		if _, _, err := commandTest(t, []string{"--load-rate", rate, "www.google.com"}); err == nil {
+			t.Fatalf("load rate %s should be refused", rate)
+		}
+	}
+}
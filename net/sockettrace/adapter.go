@@ -20,6 +20,8 @@ import (
 	"golang.org/x/net/context"
 	"net"
 	"reflect"
+	"sync"
+	"syscall"
 	"time"
 )
 
@@ -36,6 +38,11 @@ type ConnTrace struct {
 type connAdapter struct {
 	innerConn net.Conn
 	connTrace *ConnTrace
+
+	mutex          sync.Mutex
+	closed         bool
+	closingTCPInfo *TCPInfo
+	closingErr     error
 }
 
 // ContextConnTrace returns the ClientTrace associated with the
@@ -136,11 +143,44 @@ func (sta *connAdapter) Write(b []byte) (int, error) {
 	return n, err
 }
 
-// Close behaves is a proxy to the actual conn.Close
+// Close behaves is a proxy to the actual conn.Close (TCP statistics are gathered before closing)
 func (sta *connAdapter) Close() error {
+	sta.mutex.Lock()
+	if !sta.closed {
+		sta.closed = true
+		sta.closingTCPInfo, sta.closingErr = sta.readTCPInfo()
+	}
+	sta.mutex.Unlock()
+
 	return sta.innerConn.Close()
 }
 
+// TCPInfo returns the kernel statistics of the connection
+func (sta *connAdapter) TCPInfo() (*TCPInfo, error) {
+	sta.mutex.Lock()
+	defer sta.mutex.Unlock()
+
+	if sta.closed {
+		return sta.closingTCPInfo, sta.closingErr
+	}
+	return sta.readTCPInfo()
+}
+
+// Closed returns true if the connection has been closed
+func (sta *connAdapter) Closed() bool {
+	sta.mutex.Lock()
+	defer sta.mutex.Unlock()
+
+	return sta.closed
+}
+
+func (sta *connAdapter) readTCPInfo() (*TCPInfo, error) {
+	if sc, ok := sta.innerConn.(syscall.Conn); ok {
+		return readTCPInfo(sc)
+	}
+	return nil, errTCPInfoNotSupported
+}
+
 // LocalAddr behaves is a proxy to the actual conn.LocalAddr
 func (sta *connAdapter) LocalAddr() net.Addr {
 	return sta.innerConn.LocalAddr()
@@ -0,0 +1,53 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package sockettrace
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// TCPInfo is a subset of the statistics maintained by the kernel for a TCP connection
+type TCPInfo struct {
+	// RTT is the smoothed round-trip time estimated by the kernel
+	RTT time.Duration
+	// RTTVar is the variance of the round-trip time
+	RTTVar time.Duration
+	// Retransmits is the total count of retransmitted segments
+	Retransmits uint32
+	// CongestionWindow is the size of the congestion window (in segments)
+	CongestionWindow uint32
+	// MSS is the maximum segment size used to send data
+	MSS uint32
+	// DeliveryRate is the most recent delivery rate (in bytes per second), 0 if not supported by the kernel
+	DeliveryRate uint64
+}
+
+// TracedConn is the net.Conn returned by NewSocketTrace
+type TracedConn interface {
+	net.Conn
+
+	// TCPInfo returns the kernel statistics of the connection, if the connection is closed the statistics
+	// gathered just before closing are returned
+	TCPInfo() (*TCPInfo, error)
+
+	// Closed returns true if the connection has been closed
+	Closed() bool
+}
+
+var errTCPInfoNotSupported = errors.New("TCP_INFO is not supported on this platform")
@@ -0,0 +1,52 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package sockettrace
+
+import (
+	"golang.org/x/sys/unix"
+	"syscall"
+	"time"
+)
+
+func readTCPInfo(conn syscall.Conn) (*TCPInfo, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var raw *unix.TCPInfo
+	var sockErr error
+
+	if err := rawConn.Control(func(fd uintptr) {
+		raw, sockErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil {
+		return nil, err
+	}
+	if sockErr != nil {
+		return nil, sockErr
+	}
+
+	// the fields missing in older kernels (i.e. tcpi_delivery_rate before Linux 4.9) are left to 0
+	return &TCPInfo{
+		RTT:              time.Duration(raw.Rtt) * time.Microsecond,
+		RTTVar:           time.Duration(raw.Rttvar) * time.Microsecond,
+		Retransmits:      raw.Total_retrans,
+		CongestionWindow: raw.Snd_cwnd,
+		MSS:              raw.Snd_mss,
+		DeliveryRate:     raw.Delivery_rate,
+	}, nil
+}
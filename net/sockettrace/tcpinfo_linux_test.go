@@ -0,0 +1,57 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package sockettrace
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTCPInfo(t *testing.T) {
+	ts := httptest.NewServer(nil)
+	defer ts.Close()
+
+	ctx := WithTrace(context.Background(), &ConnTrace{})
+
+	conn, err := NewSocketTrace(ctx, &net.Dialer{}, "tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = conn.Write([]byte("GET / HTTP/1.0\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = conn.Read(make([]byte, 1024))
+
+	tracedConn := conn.(TracedConn)
+	info, err := tracedConn.TCPInfo()
+	if err != nil || info.MSS == 0 || info.CongestionWindow == 0 {
+		t.Fatalf("TCP statistics should be available: %v %v", info, err)
+	}
+
+	_ = conn.Close()
+
+	if !tracedConn.Closed() {
+		t.Fatal("connection should be closed")
+	}
+
+	if info, err = tracedConn.TCPInfo(); err != nil || info == nil {
+		t.Fatal("TCP statistics should still be available after closing")
+	}
+}
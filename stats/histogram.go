@@ -0,0 +1,71 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stats
+
+// Histogram counts the successful measures in buckets of equal width between Min and Max
+type Histogram struct {
+	Min    Measure
+	Max    Measure
+	Counts []int64
+}
+
+// HistogramFromLatencies builds an histogram with a number of buckets from a serie of measurements, nil if there's no
+// successful measure
+func HistogramFromLatencies(measures []Measure, buckets int) *Histogram {
+	histogram := &Histogram{Min: MeasureNotValid, Max: MeasureNotValid, Counts: make([]int64, buckets)}
+	for _, m := range measures {
+		if m.IsSuccess() {
+			if !histogram.Min.IsValid() || m < histogram.Min {
+				histogram.Min = m
+			}
+			if !histogram.Max.IsValid() || m > histogram.Max {
+				histogram.Max = m
+			}
+		}
+	}
+	if !histogram.Min.IsValid() {
+		return nil
+	}
+	// a single bucket is enough when all the measures are equal
+	if histogram.Min == histogram.Max {
+		histogram.Counts = histogram.Counts[:1]
+	}
+
+	for _, m := range measures {
+		if m.IsSuccess() {
+			histogram.Counts[histogram.Bucket(m)]++
+		}
+	}
+	return histogram
+}
+
+// Bucket returns the index of the bucket of a measure, measures out of the range are put in the first or the last one
+func (h *Histogram) Bucket(m Measure) int {
+	if h.Max == h.Min || m <= h.Min {
+		return 0
+	}
+	bucket := int(float64(m-h.Min) / float64(h.Max-h.Min) * float64(len(h.Counts)))
+	if bucket >= len(h.Counts) {
+		bucket = len(h.Counts) - 1
+	}
+	return bucket
+}
+
+// Lower returns the lower bound of a bucket
+func (h *Histogram) Lower(bucket int) Measure {
+	return h.Min + Measure(float64(h.Max-h.Min)*float64(bucket)/float64(len(h.Counts)))
+}
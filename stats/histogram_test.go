@@ -0,0 +1,45 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stats
+
+import (
+	"testing"
+)
+
+func TestHistogramFromLatencies(t *testing.T) {
+	input := []Measure{10, 11, 12, 19, 20, 55, 60, 110, MeasureNotValid}
+	histogram := HistogramFromLatencies(input, 4)
+
+	want := []int64{5, 1, 1, 1}
+	for i := range want {
+		if histogram.Counts[i] != want[i] {
+			t.Errorf("Bucket %d was incorrect, got: %d, want: %d.", i, histogram.Counts[i], want[i])
+		}
+	}
+
+	if histogram.Lower(1) != 35 || histogram.Lower(4) != 110 {
+		t.Errorf("Bounds were incorrect, got: %d and %d", histogram.Lower(1), histogram.Lower(4))
+	}
+
+	if histogram = HistogramFromLatencies([]Measure{10, 10}, 4); len(histogram.Counts) != 1 || histogram.Counts[0] != 2 {
+		t.Errorf("Histogram of equal measures should have a single bucket")
+	}
+
+	if HistogramFromLatencies([]Measure{MeasureNotValid}, 4) != nil {
+		t.Errorf("Histogram without any measure should be nil")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// Percentiles computes the percentiles ps (between 0 and 100) of the successful measures using the nearest-rank
+// method, the percentiles are not valid if there's no successful measure
+func Percentiles(measures []Measure, ps ...float64) []Measure {
+	var sorted []Measure
+	for _, m := range measures {
+		if m.IsSuccess() {
+			sorted = append(sorted, m)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	percentiles := make([]Measure, len(ps))
+	for i, p := range ps {
+		if len(sorted) == 0 {
+			percentiles[i] = MeasureNotValid
+			continue
+		}
+		rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+		if rank < 1 {
+			rank = 1
+		} else if rank > len(sorted) {
+			rank = len(sorted)
+		}
+		percentiles[i] = sorted[rank-1]
+	}
+	return percentiles
+}
+
+// CorrectedLatencies returns the latencies completed with the ones of the requests which couldn't be sent while a
+// request lasted longer than the expected interval between requests (coordinated omission), like HdrHistogram does
+// with an expected interval: a latency of 3.5 intervals adds latencies of 2.5 and 1.5 intervals
+func CorrectedLatencies(measures []Measure, expectedInterval Measure) []Measure {
+	var corrected []Measure
+	for _, m := range measures {
+		if !m.IsSuccess() {
+			continue
+		}
+		corrected = append(corrected, m)
+		if expectedInterval <= 0 {
+			continue
+		}
+		for missing := m - expectedInterval; missing >= expectedInterval; missing -= expectedInterval {
+			corrected = append(corrected, missing)
+		}
+	}
+	return corrected
+}
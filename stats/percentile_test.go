@@ -0,0 +1,60 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stats
+
+import (
+	"testing"
+)
+
+func TestPercentiles(t *testing.T) {
+	var measures []Measure
+	for i := 100; i > 0; i-- {
+		measures = append(measures, Measure(i))
+	}
+	measures = append(measures, MeasureNotValid)
+
+	got := Percentiles(measures, 0, 50, 95, 99.9, 100)
+	want := []Measure{1, 50, 95, 100, 100}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Percentile %d was incorrect, got: %d, want: %d.", i, got[i], want[i])
+		}
+	}
+
+	if Percentiles(nil, 50)[0].IsValid() {
+		t.Errorf("Percentile without any measure should be invalid")
+	}
+}
+
+func TestCorrectedLatencies(t *testing.T) {
+	got := CorrectedLatencies([]Measure{5, 35, MeasureNotValid, 10}, 10)
+	want := []Measure{5, 35, 25, 15, 10}
+
+	if len(got) != len(want) {
+		t.Fatalf("Corrected latencies were incorrect, got: %v, want: %v.", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Corrected latencies were incorrect, got: %v, want: %v.", got, want)
+		}
+	}
+
+	if len(CorrectedLatencies([]Measure{5, 35}, 0)) != 2 {
+		t.Errorf("Latencies shouldn't be corrected without interval")
+	}
+}
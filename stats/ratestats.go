@@ -0,0 +1,65 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stats
+
+import (
+	"fmt"
+	"math"
+)
+
+// RateStats represents the statistics which can be computed from an array of transfer rates (in bytes per second)
+type RateStats struct {
+	Average float64
+	Min     float64
+	Max     float64
+}
+
+// RateStatsFromRates computes RateStats from a serie of transfer rates, rates which are not positive are ignored, nil
+// is returned if there's no rate at all
+func RateStatsFromRates(rates []float64) *RateStats {
+	var sum = 0.0
+	var max = 0.0
+	var min = math.Inf(1)
+
+	count := 0
+	for _, r := range rates {
+		if r > 0 {
+			count++
+			sum += r
+			max = math.Max(max, r)
+			min = math.Min(min, r)
+		}
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	return &RateStats{
+		Min:     min,
+		Max:     max,
+		Average: sum / float64(count),
+	}
+}
+
+func (rs *RateStats) String() string {
+	mbps := func(r float64) float64 {
+		return r / 1e6
+	}
+
+	return fmt.Sprintf("throughput min/avg/max = %.3f/%.3f/%.3f MB/s", mbps(rs.Min), mbps(rs.Average), mbps(rs.Max))
+}
@@ -0,0 +1,35 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stats
+
+import (
+	"testing"
+)
+
+func TestRateStatsFromRates(t *testing.T) {
+	input := []float64{1e6, 0, 2e6, 6e6}
+	want := "throughput min/avg/max = 1.000/3.000/6.000 MB/s"
+	got := RateStatsFromRates(input).String()
+
+	if got != want {
+		t.Errorf("Throughput was incorrect, got: %s, want: %s.", got, want)
+	}
+
+	if RateStatsFromRates([]float64{0}) != nil {
+		t.Errorf("Throughput without any rate should be nil")
+	}
+}
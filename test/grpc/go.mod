@@ -0,0 +1,24 @@
+module fever.ch/http-ping/test/grpc
+
+go 1.17
+
+require (
+	fever.ch/http-ping v0.0.0
+	google.golang.org/grpc v1.43.0
+)
+
+require (
+	github.com/domainr/dnsr v0.0.0-20211217081932-6720aab3de6f // indirect
+	github.com/golang/protobuf v1.4.3 // indirect
+	github.com/miekg/dns v1.1.45 // indirect
+	golang.org/x/mod v0.5.1 // indirect
+	golang.org/x/net v0.0.0-20211216030914-fe4d6282115f // indirect
+	golang.org/x/sys v0.10.0 // indirect
+	golang.org/x/text v0.3.7 // indirect
+	golang.org/x/tools v0.1.8 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
+	google.golang.org/protobuf v1.25.0 // indirect
+)
+
+replace fever.ch/http-ping => ../..
@@ -0,0 +1,85 @@
+// Copyright 2021 Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package grpc checks the interoperability of the gRPC health check ping mode with the reference implementation of
+// gRPC, it's a separate module so that gRPC isn't a dependency of http-ping
+package grpc
+
+import (
+	"fever.ch/http-ping/app"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealthCheck(t *testing.T) {
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("backend", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("down", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	grpcServer := grpc.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	defer grpcServer.Stop()
+
+	// gRPC servers speak HTTP/2 with prior knowledge on cleartext connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = grpcServer.Serve(listener)
+	}()
+
+	tlsServer := httptest.NewUnstartedServer(grpcServer)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	targets := []string{
+		"grpc://" + listener.Addr().String(),
+		strings.Replace(tlsServer.URL, "https://", "grpcs://", 1),
+	}
+
+	for _, target := range targets {
+		for _, service := range []string{"", "backend"} {
+			grpcClient, err := app.NewGRPCClient(&app.Config{Target: target, GRPCService: service, NoCheckCertificate: true}, &app.RuntimeConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 2; i++ {
+				measure := grpcClient.DoMeasure(false)
+				if measure.IsFailure || measure.GRPCStatus != "OK" || measure.ServingStatus != "SERVING" || measure.SocketReused != (i > 0) {
+					t.Fatalf("Health check of %s (%s) should have succeed: %s", target, service, measure.FailureCause)
+				}
+			}
+		}
+
+		grpcClient, _ := app.NewGRPCClient(&app.Config{Target: target, GRPCService: "down", NoCheckCertificate: true}, &app.RuntimeConfig{})
+		if measure := grpcClient.DoMeasure(false); !measure.IsFailure || measure.FailureCause != "Service not serving (NOT_SERVING)" {
+			t.Errorf("Health check of a service not serving should have failed: %s", measure.FailureCause)
+		}
+
+		grpcClient, _ = app.NewGRPCClient(&app.Config{Target: target, GRPCService: "unknown", NoCheckCertificate: true}, &app.RuntimeConfig{})
+		if measure := grpcClient.DoMeasure(false); !measure.IsFailure || measure.FailureCause != "gRPC status NOT_FOUND: unknown service" {
+			t.Errorf("Health check of an unknown service should have failed: %s", measure.FailureCause)
+		}
+	}
+}